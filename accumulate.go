@@ -0,0 +1,60 @@
+package retry
+
+import (
+	"context"
+)
+
+// Function signature of retryable function which continues from partial
+// result of the previous attempt, see DoAccumulate
+type RetryableFuncWithAccumulator[T any] func(prev T) (T, error)
+
+type accumulateFunc[T any] struct {
+	retryableFunc RetryableFuncWithAccumulator[T]
+	value         T
+}
+
+func (f *accumulateFunc[T]) call(_ context.Context) error {
+	value, err := f.retryableFunc(f.value)
+	f.value = value
+	return err
+}
+
+func (f *accumulateFunc[T]) result() interface{} {
+	return f.value
+}
+
+func (f *accumulateFunc[T]) setResult(value interface{}) bool {
+	v, ok := value.(T)
+	if ok {
+		f.value = v
+	}
+	return ok
+}
+
+// DoAccumulate is like DoWithData for function which makes partial progress
+// (e.g. paginated fetch): result of each attempt, even the failed one, is
+// passed as prev to the next attempt (zero value to the first one),
+// it returns the last accumulated result also on failure
+//
+// The function has to continue from prev and return it merged with the new
+// data, a failed attempt returns what it accumulated so far (at least prev).
+//
+//	items, err := retry.DoAccumulate(
+//		func(prev []Item) ([]Item, error) {
+//			for {
+//				page, next, err := fetch(len(prev))
+//				if err != nil {
+//					return prev, err
+//				}
+//				prev = append(prev, page...)
+//				if !next {
+//					return prev, nil
+//				}
+//			}
+//		},
+//	)
+func DoAccumulate[T any](retryableFunc RetryableFuncWithAccumulator[T], opts ...Option) (T, error) {
+	f := &accumulateFunc[T]{retryableFunc: retryableFunc}
+	err := do(context.Background(), f, opts)
+	return f.value, err
+}
@@ -0,0 +1,55 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoAccumulate(t *testing.T) {
+	pages := [][]int{{1, 2}, {3}, {4, 5}}
+	var prevs [][]int
+	calls := 0
+	items, err := DoAccumulate(
+		func(prev []int) ([]int, error) {
+			prevs = append(prevs, prev)
+			calls++
+			// each attempt fetches one page and fails while there are more
+			prev = append(prev, pages[len(prevs)-1]...)
+			if calls < len(pages) {
+				return prev, errors.New("connection reset")
+			}
+			return prev, nil
+		},
+		Units(time.Nanosecond),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, items)
+	assert.Equal(t, [][]int{nil, {1, 2}, {1, 2, 3}}, prevs, "partial result of the previous attempt")
+
+	total, err := DoAccumulate(
+		func(prev int) (int, error) {
+			return prev + 10, errors.New("test")
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+	)
+	assert.Error(t, err)
+	assert.Len(t, err, 3)
+	assert.Equal(t, 30, total, "accumulated result on failure")
+}
+
+func TestDoAccumulateSingleFlight(t *testing.T) {
+	testSingleFlight(t, 7, func(opt Option, attempt func()) int {
+		value, _ := DoAccumulate(
+			func(prev int) (int, error) {
+				attempt()
+				return prev + 7, nil
+			},
+			opt,
+		)
+		return value
+	})
+}
@@ -0,0 +1,75 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+type adaptiveDelay struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	min      time.Duration
+	max      time.Duration
+	increase float64
+	decrease float64
+}
+
+// AdaptiveDelay returns an Option with DelayType which adapts delay to
+// recent success rate (similar to TCP congestion control)
+//
+// The delay starts at min, each failed attempt multiplies it by increase
+// and each successful attempt multiplies it by decrease, the delay stays in [min, max].
+// Attempt number, Delay and Units options are ignored.
+//
+// Its state lives across Do calls only when the same Option is reused,
+// so it requires the reusable Retrier (New). The state is safe for concurrent use.
+//
+//	r := retry.New(
+//		retry.AdaptiveDelay(10*time.Millisecond, 10*time.Second, 2, 0.5),
+//	)
+//
+//	r.Do(func() error {
+//		...
+//	})
+func AdaptiveDelay(min, max time.Duration, increase, decrease float64) Option {
+	a := &adaptiveDelay{
+		delay:    min,
+		min:      min,
+		max:      max,
+		increase: increase,
+		decrease: decrease,
+	}
+
+	return func(c *config) {
+		c.delayType = a.delayType
+		c.observe = a.observe
+	}
+}
+
+func (a *adaptiveDelay) delayType(_ uint, _ error, _ *config) time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.delay
+}
+
+// observe scales delay by result of an attempt
+func (a *adaptiveDelay) observe(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	factor := a.decrease
+	if err != nil {
+		factor = a.increase
+	}
+
+	delay := float64(a.delay) * factor
+	switch {
+	case delay > float64(a.max):
+		a.delay = a.max
+	case delay < float64(a.min):
+		a.delay = a.min
+	default:
+		a.delay = time.Duration(delay)
+	}
+}
@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveDelay(t *testing.T) {
+	min := time.Microsecond
+	max := 16 * time.Microsecond
+	delays := make(chan time.Duration, 100)
+	r := New(
+		AdaptiveDelay(min, max, 2, 0.5),
+		WithDelayChannel(delays),
+	)
+
+	// failure, success, failure, success, ...
+	var calls int
+	alternating := func() error {
+		calls++
+		if calls%2 == 1 {
+			return errors.New("test")
+		}
+		return nil
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, r.Do(alternating))
+	}
+	assert.Equal(t, []time.Duration{2 * min, 2 * min, 2 * min}, received(delays), "alternating results keep delay stable")
+
+	failing := func() error { return errors.New("test") }
+	assert.Error(t, r.Do(failing, Attempts(3)))
+	assert.Equal(t, []time.Duration{2 * min, 4 * min}, received(delays), "failures increase delay")
+
+	assert.Error(t, r.Do(failing, Attempts(5)))
+	assert.Equal(t, []time.Duration{max, max, max, max}, received(delays), "delay is bounded by max")
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, r.Do(func() error { return nil }))
+	}
+	calls = 0
+	assert.NoError(t, r.Do(alternating))
+	assert.Equal(t, []time.Duration{2 * min}, received(delays), "successes decrease delay down to min")
+
+	assert.Error(t, Do(failing, Attempts(2), AdaptiveDelay(min, max, 2, 0.5), WithDelayChannel(delays)))
+	assert.Equal(t, []time.Duration{2 * min}, received(delays), "new option has new state")
+}
+
+func received(delays chan time.Duration) []time.Duration {
+	var r []time.Duration
+	for {
+		select {
+		case delay := <-delays:
+			r = append(r, delay)
+		default:
+			return r
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package retry
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// DoAll retries each function concurrently by Do with opts and returns
+// their errors in order of fns (nil for succeeded function)
+//
+// Count of functions in flight is limited by WithConcurrency.
+// opts are applied once more to read the limit, so options with side
+// effects run one extra time.
+//
+//	errs := retry.DoAll([]retry.RetryableFunc{fetchUsers, fetchOrders},
+//		retry.Attempts(3),
+//		retry.WithConcurrency(2),
+//	)
+func DoAll(fns []RetryableFunc, opts ...Option) []error {
+	concurrency := runtime.GOMAXPROCS(0)
+	c := newConfig(context.Background())
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.concurrency > 0 {
+		concurrency = c.concurrency
+	}
+	if c.stackTrace {
+		// Do runs in other goroutine, its stack doesn't reach the caller
+		opts = append(append([]Option{}, opts...), callerStack(callers(1)))
+	}
+	releaseConfig(c)
+
+	errs := make([]error, len(fns))
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, fn := range fns {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(i int, fn RetryableFunc) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			errs[i] = Do(fn, opts...)
+		}(i, fn)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// WithConcurrency set maximal count of functions retried by DoAll at once
+// default is runtime.GOMAXPROCS(0), non-positive n keeps the default
+// Do ignores it.
+func WithConcurrency(n int) Option {
+	return func(c *config) {
+		c.concurrency = n
+	}
+}
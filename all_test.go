@@ -0,0 +1,61 @@
+package retry
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoAll(t *testing.T) {
+	errFailing := errors.New("failing")
+	var flakyCalls int32
+	errs := DoAll(
+		[]RetryableFunc{
+			func() error { return nil },
+			func() error { return errFailing },
+			func() error {
+				if atomic.AddInt32(&flakyCalls, 1) < 3 {
+					return errors.New("flaky")
+				}
+				return nil
+			},
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+	)
+
+	assert.Len(t, errs, 3)
+	assert.NoError(t, errs[0])
+	assert.True(t, errors.Is(errs[1], errFailing), "errors in order of functions")
+	assert.Len(t, errs[1], 3, "each function has all attempts")
+	assert.NoError(t, errs[2])
+}
+
+func TestDoAllWithConcurrency(t *testing.T) {
+	var running, highWater int32
+	fn := func() error {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&highWater)
+			if current <= max || atomic.CompareAndSwapInt32(&highWater, max, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return errors.New("test")
+	}
+
+	fns := make([]RetryableFunc, 20)
+	for i := range fns {
+		fns[i] = fn
+	}
+
+	errs := DoAll(fns, Attempts(2), Units(time.Nanosecond), WithConcurrency(3))
+	assert.Len(t, errs, 20)
+	assert.True(t, atomic.LoadInt32(&highWater) <= 3, "no more than 3 functions at once")
+	assert.True(t, atomic.LoadInt32(&highWater) > 1, "functions run concurrently")
+}
@@ -0,0 +1,83 @@
+package retry
+
+import (
+	"fmt"
+	"strings"
+)
+
+// BackoffType enumerates built-in delay types,
+// it allows to select delay type by name (e.g. from config file)
+type BackoffType int
+
+const (
+	// BackoffFixed selects FixedDelay
+	BackoffFixed BackoffType = iota
+	// BackoffLinear selects LinearDelay
+	BackoffLinear
+	// BackoffExponential selects BackOffDelay
+	BackoffExponential
+	// BackoffFibonacci selects FibonacciDelay
+	BackoffFibonacci
+)
+
+var backoffNames = map[BackoffType]string{
+	BackoffFixed:       "fixed",
+	BackoffLinear:      "linear",
+	BackoffExponential: "exponential",
+	BackoffFibonacci:   "fibonacci",
+}
+
+var backoffDelayTypes = map[BackoffType]DelayTypeFunc{
+	BackoffFixed:       FixedDelay,
+	BackoffLinear:      LinearDelay,
+	BackoffExponential: BackOffDelay,
+	BackoffFibonacci:   FibonacciDelay,
+}
+
+// String returns name of backoff type, it is accepted by ParseBackoffType
+func (b BackoffType) String() string {
+	if name, ok := backoffNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("BackoffType(%d)", int(b))
+}
+
+// ParseBackoffType returns backoff type of given name (case insensitive)
+func ParseBackoffType(s string) (BackoffType, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for b, n := range backoffNames {
+		if n == name {
+			return b, nil
+		}
+	}
+	return 0, fmt.Errorf("retry: unknown backoff type %q", s)
+}
+
+// Backoff set DelayType by backoff type
+// default is BackoffFixed, unknown backoff type is an error returned by Do
+// (a later known type overrides it)
+//
+// delay type from config example:
+//
+//	backoff, err := retry.ParseBackoffType(cfg.Backoff)
+//	if err != nil {
+//		return err
+//	}
+//
+//	retry.Do(
+//		func() error {
+//			...
+//		},
+//		retry.Backoff(backoff),
+//	)
+func Backoff(b BackoffType) Option {
+	return func(c *config) {
+		delayType, ok := backoffDelayTypes[b]
+		if !ok {
+			c.err = fmt.Errorf("%w %s", errUnknownBackoff, b)
+			return
+		}
+		c.clearError(errUnknownBackoff)
+		c.delayType = delayType
+	}
+}
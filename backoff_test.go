@@ -0,0 +1,76 @@
+package retry
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuiltinDelayTypes(t *testing.T) {
+	c := &config{baseDelay: 10 * time.Millisecond}
+	ms := time.Millisecond
+
+	var linear, backoff, fibonacci []time.Duration
+	for n := uint(0); n < 6; n++ {
+		linear = append(linear, LinearDelay(n, nil, c))
+		backoff = append(backoff, BackOffDelay(n, nil, c))
+		fibonacci = append(fibonacci, FibonacciDelay(n, nil, c))
+	}
+
+	assert.Equal(t, []time.Duration{10 * ms, 20 * ms, 30 * ms, 40 * ms, 50 * ms, 60 * ms}, linear)
+	assert.Equal(t, []time.Duration{10 * ms, 20 * ms, 40 * ms, 80 * ms, 160 * ms, 320 * ms}, backoff)
+	assert.Equal(t, []time.Duration{10 * ms, 10 * ms, 20 * ms, 30 * ms, 50 * ms, 80 * ms}, fibonacci)
+
+	assert.Equal(t, time.Duration(math.MaxInt64), BackOffDelay(100, nil, c), "overflow is saturated")
+	assert.Equal(t, time.Duration(math.MaxInt64), FibonacciDelay(200, nil, c), "overflow is saturated")
+}
+
+func TestParseBackoffType(t *testing.T) {
+	for _, b := range []BackoffType{BackoffFixed, BackoffLinear, BackoffExponential, BackoffFibonacci} {
+		parsed, err := ParseBackoffType(b.String())
+		assert.NoError(t, err)
+		assert.Equal(t, b, parsed, "round trip of %s", b)
+	}
+
+	parsed, err := ParseBackoffType(" Exponential ")
+	assert.NoError(t, err)
+	assert.Equal(t, BackoffExponential, parsed, "case insensitive")
+
+	_, err = ParseBackoffType("quadratic")
+	assert.Error(t, err)
+
+	assert.Equal(t, "BackoffType(42)", BackoffType(42).String())
+}
+
+func TestBackoff(t *testing.T) {
+	c := &config{baseDelay: 10 * time.Millisecond, delayType: FixedDelay}
+
+	Backoff(BackoffExponential)(c)
+	assert.Equal(t, 40*time.Millisecond, c.delayType(2, nil, c))
+
+	calls := 0
+	err := Do(
+		func() error {
+			calls++
+			return nil
+		},
+		Backoff(BackoffType(42)),
+	)
+	assert.EqualError(t, err, "retry: unknown backoff type BackoffType(42)")
+	assert.Equal(t, 0, calls, "no attempt with invalid config")
+}
+
+func TestRampedBackoff(t *testing.T) {
+	delayType := RampedBackoff(100*time.Millisecond, 6400*time.Millisecond, 6)
+
+	assert.Equal(t, 100*time.Millisecond, delayType(0, nil, nil), "start at attempt 0")
+	assert.Equal(t, 800*time.Millisecond, delayType(3, nil, nil), "mid-ramp")
+	assert.Equal(t, 3200*time.Millisecond, delayType(5, nil, nil))
+	assert.Equal(t, 6400*time.Millisecond, delayType(6, nil, nil), "cap at the given attempt")
+	assert.Equal(t, 6400*time.Millisecond, delayType(100, nil, nil), "capped after that")
+
+	assert.Equal(t, time.Second, RampedBackoff(time.Second, time.Second, 3)(0, nil, nil), "start at cap")
+	assert.Equal(t, time.Second, RampedBackoff(time.Millisecond, time.Second, 0)(0, nil, nil), "no ramp")
+}
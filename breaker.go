@@ -0,0 +1,37 @@
+package retry
+
+import (
+	"errors"
+)
+
+// ErrCircuitOpen is collected when Breaker doesn't allow an attempt
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// Breaker is a circuit breaker consulted by Do, see WithBreaker
+//
+// It's minimal so it's easy to adapt existing implementations
+// (e.g. sony/gobreaker) to it.
+type Breaker interface {
+	// Allow reports whether an attempt may be made
+	//
+	// It's called before waiting for a retry and again right before it
+	// (the breaker may open during the wait), so it may be called more
+	// than once per attempt and shouldn't count calls (e.g. as half-open
+	// trials), count them by RecordSuccess and RecordFailure instead.
+	Allow() bool
+	// RecordSuccess records successful attempt
+	RecordSuccess()
+	// RecordFailure records failed attempt
+	RecordFailure()
+}
+
+// WithBreaker makes Do consult the breaker before each attempt and before
+// waiting for each next one, and record result of each attempt to it
+//
+// When the breaker doesn't allow an attempt, Do stops immediately
+// (without waiting) and ErrCircuitOpen is the last error of the result.
+func WithBreaker(b Breaker) Option {
+	return func(c *config) {
+		c.breaker = b
+	}
+}
@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingBreaker opens after maxFailures failures in a row
+type countingBreaker struct {
+	maxFailures int
+	failures    int
+	successes   int
+}
+
+func (b *countingBreaker) Allow() bool    { return b.failures < b.maxFailures }
+func (b *countingBreaker) RecordSuccess() { b.successes++; b.failures = 0 }
+func (b *countingBreaker) RecordFailure() { b.failures++ }
+
+func TestWithBreaker(t *testing.T) {
+	breaker := &countingBreaker{maxFailures: 2}
+	var calls int
+	err := Do(
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		Units(time.Nanosecond),
+		WithBreaker(breaker),
+	)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrCircuitOpen), "stopped by open circuit")
+	assert.Equal(t, 2, calls, "no attempt with open circuit")
+	assert.Len(t, err, 3)
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		WithBreaker(breaker),
+	)
+	assert.Error(t, err, "known-bad dependency isn't called at all")
+	assert.Equal(t, 0, calls)
+
+	breaker.failures = 0
+	err = Do(
+		func() error {
+			calls++
+			if calls == 1 {
+				return errors.New("test")
+			}
+			return nil
+		},
+		Units(time.Nanosecond),
+		WithBreaker(breaker),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, breaker.successes, "success is recorded")
+	assert.Equal(t, 0, breaker.failures)
+}
+
+// trippingTimer opens the breaker while waiting
+type trippingTimer struct {
+	breaker *countingBreaker
+}
+
+func (t trippingTimer) After(time.Duration) <-chan time.Time {
+	t.breaker.failures = t.breaker.maxFailures
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+func TestWithBreakerOpenedDuringDelay(t *testing.T) {
+	breaker := &countingBreaker{maxFailures: 5}
+	var calls int
+	err := Do(
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		WithBreaker(breaker),
+		WithTimer(trippingTimer{breaker: breaker}),
+	)
+	assert.Equal(t, 1, calls, "no attempt after the breaker opened during the delay")
+	assert.True(t, errors.Is(err, ErrCircuitOpen))
+}
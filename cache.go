@@ -0,0 +1,89 @@
+package retry
+
+import (
+	"sync"
+	"time"
+)
+
+type resultCache struct {
+	ttl time.Duration
+	key func() string
+}
+
+type cachedResult struct {
+	value   interface{}
+	expires time.Time
+}
+
+// interval of dropping of expired results, see resultStore.set
+const cacheSweepInterval = time.Minute
+
+// resultStore is a process-global store of results cached by WithResultCache
+type resultStore struct {
+	mu        sync.Mutex
+	results   map[string]cachedResult
+	lastSweep time.Time
+}
+
+var results = &resultStore{results: make(map[string]cachedResult)}
+
+// get returns result of key which isn't expired at now
+func (s *resultStore) get(key string, now time.Time) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result, ok := s.results[key]
+	if !ok {
+		return nil, false
+	}
+	if !now.Before(result.expires) {
+		delete(s.results, key)
+		return nil, false
+	}
+	return result.value, true
+}
+
+// set stores result of key for ttl from now, it drops all expired results
+// at most once per cacheSweepInterval (so it doesn't scan the store each time)
+func (s *resultStore) set(key string, value interface{}, ttl time.Duration, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) >= cacheSweepInterval || now.Before(s.lastSweep) {
+		for k, result := range s.results {
+			if !now.Before(result.expires) {
+				delete(s.results, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.results[key] = cachedResult{value: value, expires: now.Add(ttl)}
+}
+
+// WithResultCache caches successful result of DoWithData for ttl under key
+// so concurrent and following calls with the same key take it instead
+// of retrying (e.g. 50 goroutines fetching the same config)
+// Do and other functions without data ignore it.
+//
+// The cache is checked before each attempt, so a success satisfies calls
+// which are retrying at the same time as well (combine with WithSingleFlight
+// to share in-flight attempts too). key is called before each attempt.
+//
+// The cache is process-global, so keys should be unique across the process
+// (e.g. prefixed by name of operation). A result of another type than the
+// calling DoWithData is a miss. Expiration is measured by Clock.
+//
+// Results are kept in memory after they expire: an expired result is dropped
+// when its key is read, and all expired results are dropped by storing of
+// a result at most once a minute. So memory of the cache is bounded by
+// results stored during ttl plus a minute, keep ttl short: a cached result
+// may be up to ttl old.
+//
+//	cfg, err := retry.DoWithData(fetchConfig,
+//		retry.WithResultCache(5*time.Second, func() string { return "config:" + name }),
+//	)
+func WithResultCache(ttl time.Duration, key func() string) Option {
+	return func(c *config) {
+		c.resultCache = &resultCache{ttl: ttl, key: key}
+	}
+}
@@ -0,0 +1,154 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithResultCache(t *testing.T) {
+	key := func() string { return "TestWithResultCache" }
+	var calls int
+	fetch := func() (string, error) {
+		calls++
+		return "config", nil
+	}
+
+	value, err := DoWithData(fetch, WithResultCache(time.Minute, key))
+	assert.NoError(t, err)
+	assert.Equal(t, "config", value)
+
+	value, err = DoWithData(fetch, WithResultCache(time.Minute, key))
+	assert.NoError(t, err)
+	assert.Equal(t, "config", value)
+	assert.Equal(t, 1, calls, "cached result")
+
+	number, err := DoWithData(func() (int, error) { return 1, nil }, WithResultCache(time.Minute, key))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, number, "result of other type is a miss")
+
+	clock := &stepClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	expiring := WithResultCache(time.Second, func() string { return "TestWithResultCache/expired" })
+	_, err = DoWithData(fetch, expiring, WithClock(clock))
+	assert.NoError(t, err)
+	clock.now = clock.now.Add(999 * time.Millisecond)
+	_, err = DoWithData(fetch, expiring, WithClock(clock))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls, "not expired yet")
+	clock.now = clock.now.Add(time.Millisecond)
+	_, err = DoWithData(fetch, expiring, WithClock(clock))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls, "expired result by Clock")
+}
+
+func TestResultStoreSweep(t *testing.T) {
+	store := &resultStore{results: make(map[string]cachedResult)}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store.set("a", 1, time.Second, now)
+	store.set("b", 2, time.Hour, now.Add(2*time.Second))
+	assert.Len(t, store.results, 2, "no sweep within interval")
+
+	store.set("c", 3, time.Hour, now.Add(cacheSweepInterval))
+	assert.Len(t, store.results, 2, "expired result is swept")
+	_, ok := store.get("a", now)
+	assert.False(t, ok)
+
+	store.set("d", 4, time.Second, now.Add(cacheSweepInterval))
+	_, ok = store.get("d", now.Add(cacheSweepInterval+time.Second))
+	assert.False(t, ok, "expired on get")
+	assert.Len(t, store.results, 2)
+}
+
+func TestWithResultCacheConcurrent(t *testing.T) {
+	key := func() string { return "TestWithResultCacheConcurrent" }
+	var calls int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], _ = DoWithData(
+				func() (string, error) {
+					if atomic.AddInt32(&calls, 1) == 1 {
+						<-release
+						return "config", nil
+					}
+					return "", errors.New("test")
+				},
+				Attempts(0),
+				Delay(time.Millisecond),
+				Units(1),
+				WithResultCache(time.Minute, key),
+			)
+		}(i)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, value := range values {
+		assert.Equal(t, "config", value, "success satisfies retrying calls")
+	}
+}
+
+func TestDoWithDataSingleFlight(t *testing.T) {
+	testSingleFlight(t, 42, func(opt Option, attempt func()) int {
+		value, _ := DoWithData(
+			func() (int, error) {
+				attempt()
+				return 42, nil
+			},
+			opt,
+		)
+		return value
+	})
+}
+
+func TestSingleFlightOtherType(t *testing.T) {
+	var group Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var text string
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		text, _ = DoWithData(
+			func() (string, error) {
+				close(started)
+				<-release
+				return "config", nil
+			},
+			WithSingleFlight("TestSingleFlightOtherType", &group),
+		)
+	}()
+	<-started
+
+	var number int
+	var err error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		number, err = DoWithData(
+			func() (int, error) { return 42, nil },
+			WithSingleFlight("TestSingleFlightOtherType", &group),
+		)
+	}()
+	waitFor(t, func() bool { return group.waiters("TestSingleFlightOtherType") == 1 })
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, "config", text)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, number, "shared value of other type is a miss")
+}
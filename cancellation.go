@@ -0,0 +1,46 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// errors which are recognized as cancellation by IsCancellation
+var cancellationErrors = []error{
+	context.Canceled,
+	context.DeadlineExceeded,
+	sql.ErrConnDone,
+}
+
+// IsCancellation checks if err (or any error in its chain) is a cancellation
+// which is pointless to retry: context.Canceled, context.DeadlineExceeded,
+// sql.ErrConnDone or any of sentinels
+//
+//	retry.IsCancellation(err, grpcCanceled, driver.ErrBadConn)
+func IsCancellation(err error, sentinels ...error) bool {
+	for _, sentinel := range cancellationErrors {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	return false
+}
+
+// StopOnCancellation stops retrying when the error of an attempt is
+// a cancellation (see IsCancellation, sentinels extend the recognized errors)
+// default is false
+//
+// It takes precedence over RetryIf, but RetryOnPerAttemptTimeout still
+// decides about deadlines of attempts.
+func StopOnCancellation(stop bool, sentinels ...error) Option {
+	return func(c *config) {
+		c.stopOnCancellation = stop
+		c.cancellationSentinels = sentinels
+	}
+}
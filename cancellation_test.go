@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsCancellation(t *testing.T) {
+	aborted := errors.New("operation aborted")
+
+	assert.True(t, IsCancellation(context.Canceled))
+	assert.True(t, IsCancellation(fmt.Errorf("query: %w", context.DeadlineExceeded)))
+	assert.True(t, IsCancellation(fmt.Errorf("exec: %w", sql.ErrConnDone)))
+	assert.False(t, IsCancellation(errors.New("connection refused")))
+	assert.False(t, IsCancellation(nil))
+
+	assert.False(t, IsCancellation(aborted))
+	assert.True(t, IsCancellation(fmt.Errorf("rpc: %w", aborted), aborted), "extra sentinel")
+}
+
+func TestStopOnCancellation(t *testing.T) {
+	aborted := errors.New("operation aborted")
+	errs := []error{errors.New("test"), fmt.Errorf("query: %w", context.Canceled), errors.New("test")}
+
+	calls := 0
+	err := Do(
+		func() error {
+			err := errs[calls]
+			calls++
+			return err
+		},
+		Units(time.Nanosecond),
+		StopOnCancellation(true),
+	)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 2, calls, "stops on cancellation")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return aborted
+		},
+		Units(time.Nanosecond),
+		StopOnCancellation(true, aborted),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "stops on extra sentinel")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return context.Canceled
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		StopOnCancellation(false),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls, "disabled")
+}
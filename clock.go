@@ -0,0 +1,44 @@
+package retry
+
+import (
+	"time"
+)
+
+// Clock tells current time to Do, see WithClock
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Timer waits for delays of Do, see WithTimer
+type Timer interface {
+	// After returns channel which receives when delay elapses
+	After(delay time.Duration) <-chan time.Time
+}
+
+// WithTimer set timer used for all waiting of Do (delays between attempts,
+// WithStartupJitter, WithSettleTime)
+// default is the real timer
+//
+// It's mostly for tests purpose, e.g. a fake timer which records delays
+// and elapses immediately.
+func WithTimer(timer Timer) Option {
+	return func(c *config) {
+		c.timer = timer
+	}
+}
+
+// WithClock set clock used for measuring of time (e.g. by WithElapsedInError)
+// default is the wall clock
+//
+// It's mostly for tests purpose.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
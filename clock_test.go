@@ -0,0 +1,75 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stepClock moves by step on each call of Now
+type stepClock struct {
+	now  time.Time
+	step time.Duration
+}
+
+func (c *stepClock) Now() time.Time {
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+func TestWithElapsedInError(t *testing.T) {
+	originalErr := errors.New("connection refused")
+	err := Do(
+		func() error { return originalErr },
+		Attempts(4),
+		Units(time.Nanosecond),
+		WithClock(&stepClock{step: 6200 * time.Millisecond}),
+		WithElapsedInError(true),
+	)
+	assert.EqualError(t, err, "retry: failed after 4 attempts over 6.2s: connection refused")
+	assert.True(t, errors.Is(err, originalErr), "unwraps to the last error")
+
+	var retryErr Error
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Len(t, retryErr, 4)
+
+	err = Do(
+		func() error { return Unrecoverable(originalErr) },
+		WithClock(&stepClock{step: time.Second}),
+		WithElapsedInError(true),
+	)
+	assert.EqualError(t, err, "retry: failed after 1 attempts over 1s: connection refused")
+
+	assert.NoError(t, Do(func() error { return nil }, WithElapsedInError(true)))
+}
+
+func TestWithTimestamps(t *testing.T) {
+	originalErr := errors.New("test")
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	err := Do(
+		func() error { return originalErr },
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithClock(&stepClock{now: start, step: time.Second}),
+		WithTimestamps(true),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "test", err.Error(), "message is unchanged")
+	assert.True(t, errors.Is(err, originalErr), "timestamped error unwraps to the original")
+
+	timed := err.(Error).TimedErrors()
+	assert.Equal(t, []TimedError{
+		{At: start, Err: originalErr},
+		{At: start.Add(time.Second), Err: originalErr},
+		{At: start.Add(2 * time.Second), Err: originalErr},
+	}, timed)
+
+	err = Do(
+		func() error { return originalErr },
+		Attempts(1),
+	)
+	assert.Equal(t, []TimedError{{Err: originalErr}}, err.(Error).TimedErrors(), "no timestamps by default")
+}
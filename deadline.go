@@ -0,0 +1,87 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// maximal delay of DoUntilDeadline as a multiple of its base delay
+const deadlineMaxDelayFactor = 32
+
+// deadlineError is returned when DoUntilDeadline runs out of time
+type deadlineError struct {
+	ctxErr error
+	// err is the error made by Do (by its options)
+	err error
+	// last is the last error of retryable function
+	last error
+}
+
+func (e *deadlineError) Error() string {
+	return fmt.Sprintf("retry: %s: %s", e.ctxErr, e.last)
+}
+
+// Unwrap returns the error made by Do and the last error of retryable function
+func (e *deadlineError) Unwrap() []error {
+	return []error{e.err, e.last}
+}
+
+// Is reports the context error as well (e.g. context.DeadlineExceeded)
+func (e *deadlineError) Is(target error) bool {
+	return target == e.ctxErr
+}
+
+// DoUntilDeadline retries retryable function until ctx is done
+// (usually by its deadline), so there is no need to fit count of attempts
+// to the deadline
+//
+// It uses unlimited attempts and exponential backoff from base capped at
+// 32 times base, opts are applied on top of that (e.g. MaxDelay overrides the cap).
+// Context without deadline retries until it's cancelled.
+//
+// When ctx is done, the returned error satisfies errors.Is(err, ctx.Err())
+// and unwraps to the last error of retryable function and to the error
+// made by opts (e.g. Error with all errors). Non-positive base is an error.
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+//	defer cancel()
+//
+//	err := retry.DoUntilDeadline(ctx,
+//		func() error {
+//			...
+//		},
+//		100*time.Millisecond,
+//	)
+func DoUntilDeadline(ctx context.Context, retryableFunc RetryableFunc, base time.Duration, opts ...Option) error {
+	var last error
+	deadlineOpts := []Option{
+		Attempts(0),
+		DelayType(BackOffDelay),
+		DelayDuration(base),
+		MaxDelay(base * deadlineMaxDelayFactor),
+	}
+	if base <= 0 {
+		// zero backoff with unlimited attempts would spin until the deadline
+		deadlineOpts = append(deadlineOpts, func(c *config) {
+			c.err = errors.New("retry: non-positive base delay")
+		})
+	}
+
+	err := do(ctx,
+		RetryableFunc(func() error {
+			err := retryableFunc()
+			if err != nil {
+				last = err
+			}
+			return err
+		}),
+		append(deadlineOpts, opts...),
+	)
+
+	if err != nil && ctx.Err() != nil && last != nil {
+		return &deadlineError{ctxErr: ctx.Err(), err: err, last: last}
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoUntilDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	originalErr := errors.New("test")
+	delays := make(chan time.Duration, 100)
+	var calls int
+	err := DoUntilDeadline(ctx,
+		func() error {
+			calls++
+			return originalErr
+		},
+		time.Millisecond,
+		WithDelayChannel(delays),
+	)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "deadline exhaustion")
+	assert.True(t, errors.Is(err, originalErr), "unwraps to the last error")
+	assert.Equal(t, "retry: context deadline exceeded: test", err.Error())
+	assert.True(t, calls > 1, "retried until deadline")
+
+	for _, delay := range received(delays) {
+		assert.True(t, delay <= 32*time.Millisecond, "capped backoff")
+	}
+}
+
+func TestDoUntilDeadlineNotRetryable(t *testing.T) {
+	originalErr := errors.New("test")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	err := DoUntilDeadline(ctx,
+		func() error { return Unrecoverable(originalErr) },
+		time.Millisecond,
+	)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, context.DeadlineExceeded), "not a deadline exhaustion")
+	assert.True(t, errors.Is(err, originalErr))
+}
+
+func TestDoUntilDeadlineKeepsOptions(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	originalErr := errors.New("test")
+	err := DoUntilDeadline(ctx,
+		func() error { return originalErr },
+		time.Millisecond,
+		WithStackTrace(true),
+		WithLabel("db"),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.Is(err, originalErr))
+
+	var retryErr Error
+	assert.True(t, errors.As(err, &retryErr), "error made by Do is kept")
+	assert.True(t, len(retryErr) > 1, "with all errors")
+	assert.EqualError(t, retryErr[0], "db: test", "labeled by the option")
+
+	var stack interface{ StackTrace() []runtime.Frame }
+	assert.True(t, errors.As(err, &stack), "stack trace by the option")
+}
+
+func TestDoUntilDeadlineNonPositiveBase(t *testing.T) {
+	calls := 0
+	err := DoUntilDeadline(context.Background(),
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		0,
+	)
+	assert.EqualError(t, err, "retry: non-positive base delay")
+	assert.Equal(t, 0, calls)
+}
@@ -0,0 +1,95 @@
+package retry_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundRobinEndpoints(t *testing.T) {
+	var hits []string
+	newServer := func(name string, status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+			w.WriteHeader(status)
+		}))
+	}
+
+	down := newServer("down", http.StatusServiceUnavailable)
+	defer down.Close()
+	up := newServer("up", http.StatusOK)
+	defer up.Close()
+
+	endpoints := []string{down.URL, up.URL}
+	var endpoint string
+
+	err := retry.Do(
+		func() error {
+			resp, err := http.Get(endpoint)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("%s: %s", endpoint, resp.Status)
+			}
+			return nil
+		},
+		retry.BeforeAttempt(func(n uint) {
+			// attempt n targets endpoint n%len
+			endpoint = endpoints[n%uint(len(endpoints))]
+		}),
+		retry.Units(time.Nanosecond),
+	)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"down", "up"}, hits)
+}
+
+func TestWeightedEndpoints(t *testing.T) {
+	hits := map[string]int{}
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[name]++
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+	}
+
+	primary := newServer("primary")
+	defer primary.Close()
+	secondary := newServer("secondary")
+	defer secondary.Close()
+
+	// primary has weight 3, secondary weight 1
+	endpoints := []string{primary.URL, primary.URL, primary.URL, secondary.URL}
+	var endpoint string
+
+	err := retry.Do(
+		func() error {
+			resp, err := http.Get(endpoint)
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("%s: %s", endpoint, resp.Status)
+			}
+			return nil
+		},
+		retry.BeforeAttempt(func(n uint) {
+			endpoint = endpoints[n%uint(len(endpoints))]
+		}),
+		retry.Attempts(8),
+		retry.Units(time.Nanosecond),
+	)
+
+	assert.Error(t, err)
+	assert.Equal(t, map[string]int{"primary": 6, "secondary": 2}, hits)
+}
@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"errors"
+)
+
+// reasons of giving up passed to OnGiveUp (besides ErrCircuitOpen,
+// ErrPlanRejected, error of Context and error of invalid config)
+var (
+	// ErrAttemptsExhausted means all attempts (including grace attempts) failed
+	ErrAttemptsExhausted = errors.New("retry: attempts exhausted")
+	// ErrNotRetryable means the last error is unrecoverable or not retried
+	// (e.g. by RetryIf, StopOnCancellation)
+	ErrNotRetryable = errors.New("retry: error is not retryable")
+	// ErrSameErrorLimit means limit of MaxConsecutiveSameError was reached
+	ErrSameErrorLimit = errors.New("retry: too many consecutive same errors")
+)
+
+// Function signature of OnGiveUp function
+type OnGiveUpFunc func(attempts uint, errs []error, reason error)
+
+// OnGiveUp function callback is called once right before Do returns
+// an error, whatever is the reason
+//
+// errs are errors of all attempts (only the last one with LastErrorOnly or
+// WithoutErrorCollection), reason is why Do gave up: ErrAttemptsExhausted,
+// ErrNotRetryable, ErrSameErrorLimit, ErrCircuitOpen, ErrPlanRejected,
+// error of Context, or error of invalid config (with no attempts).
+//
+// page on failure example:
+//
+//	retry.Do(
+//		func() error {
+//			return errors.New("some error")
+//		},
+//		retry.OnGiveUp(func(attempts uint, errs []error, reason error) {
+//			pager.Alert(fmt.Sprintf("gave up after %d attempts (%s): %v", attempts, reason, errs))
+//		}),
+//	)
+func OnGiveUp(onGiveUp OnGiveUpFunc) Option {
+	return func(c *config) {
+		c.onGiveUp = onGiveUp
+	}
+}
+
+// giveUp calls OnGiveUp callback
+func (c *config) giveUp(attempts uint, errs []error, reason error) {
+	if c.onGiveUp != nil {
+		c.onGiveUp(attempts, errs, reason)
+	}
+}
@@ -0,0 +1,73 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type giveUp struct {
+	calls    int
+	attempts uint
+	errs     []error
+	reason   error
+}
+
+func (g *giveUp) option() Option {
+	return OnGiveUp(func(attempts uint, errs []error, reason error) {
+		g.calls++
+		g.attempts = attempts
+		g.errs = errs
+		g.reason = reason
+	})
+}
+
+func TestOnGiveUp(t *testing.T) {
+	testErr := errors.New("test")
+
+	var g giveUp
+	err := Do(func() error { return testErr }, Attempts(3), Units(time.Nanosecond), g.option())
+	assert.Error(t, err)
+	assert.Equal(t, 1, g.calls, "exactly once")
+	assert.Equal(t, uint(3), g.attempts)
+	assert.Equal(t, []error{testErr, testErr, testErr}, g.errs)
+	assert.Equal(t, ErrAttemptsExhausted, g.reason)
+
+	g = giveUp{}
+	err = Do(func() error { return Unrecoverable(testErr) }, g.option())
+	assert.Error(t, err)
+	assert.Equal(t, ErrNotRetryable, g.reason)
+	assert.Equal(t, uint(1), g.attempts)
+
+	g = giveUp{}
+	err = Do(func() error { return testErr }, Units(time.Nanosecond), MaxConsecutiveSameError(2), LastErrorOnly(true), g.option())
+	assert.Error(t, err)
+	assert.Equal(t, ErrSameErrorLimit, g.reason)
+	assert.Equal(t, []error{testErr}, g.errs, "only the last error")
+
+	g = giveUp{}
+	err = Do(func() error { return testErr }, WithBreaker(&countingBreaker{}), g.option())
+	assert.Error(t, err)
+	assert.Equal(t, ErrCircuitOpen, g.reason)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	g = giveUp{}
+	err = Do(func() error { return testErr }, Context(ctx), g.option())
+	assert.Error(t, err)
+	assert.Equal(t, context.Canceled, g.reason)
+
+	g = giveUp{}
+	err = Do(func() error { return nil }, WithDelaySchedule(nil), g.option())
+	assert.Error(t, err)
+	assert.Equal(t, err, g.reason, "invalid config")
+	assert.Equal(t, uint(0), g.attempts)
+
+	g = giveUp{}
+	assert.NoError(t, Do(func() error { return nil }, g.option()))
+	assert.NoError(t, Do(func() error { return Stop }, g.option()))
+	assert.Equal(t, 0, g.calls, "not called without error")
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"context"
+	"time"
+)
+
+// Function signature of hedged function, see DoHedged
+type HedgedFunc[T any] func(ctx context.Context) (T, error)
+
+type hedgeResult[T any] struct {
+	value T
+	err   error
+}
+
+type hedgedFunc[T any] struct {
+	retryableFunc HedgedFunc[T]
+	hedgeDelay    time.Duration
+	maxHedges     int
+	// timer of Do, see WithTimer
+	timer Timer
+	value T
+}
+
+func (f *hedgedFunc[T]) result() interface{} {
+	return f.value
+}
+
+func (f *hedgedFunc[T]) setResult(value interface{}) bool {
+	v, ok := value.(T)
+	if ok {
+		f.value = v
+	}
+	return ok
+}
+
+// call makes one hedged attempt: it starts the function and one more
+// each hedgeDelay (up to maxHedges more) while none of them succeeded
+func (f *hedgedFunc[T]) call(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	// cancels the losers
+	defer cancel()
+
+	// buffered, so the losers don't block after the attempt returned
+	results := make(chan hedgeResult[T], f.maxHedges+1)
+	launch := func() {
+		go func() {
+			value, err := f.retryableFunc(ctx)
+			results <- hedgeResult[T]{value: value, err: err}
+		}()
+	}
+
+	launch()
+	launched := 1
+	var hedge <-chan time.Time
+	stop := func() {}
+	defer func() { stop() }()
+	if f.maxHedges > 0 {
+		hedge, stop = after(f.timer, f.hedgeDelay)
+	}
+
+	var errs Error
+	recoverable := true
+	for len(errs) < launched {
+		select {
+		case r := <-results:
+			if r.err == nil {
+				f.value = r.value
+				return nil
+			}
+			if r.err == Stop {
+				return Stop
+			}
+			if !IsRecoverable(r.err) {
+				recoverable = false
+				r.err = r.err.(unrecoverableError).error
+			}
+			errs = append(errs, r.err)
+		case <-hedge:
+			launch()
+			launched++
+			stop()
+			if launched > f.maxHedges {
+				hedge, stop = nil, func() {}
+			} else {
+				hedge, stop = after(f.timer, f.hedgeDelay)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var err error = errs
+	if len(errs) == 1 {
+		err = errs[0]
+	}
+	if !recoverable {
+		return Unrecoverable(err)
+	}
+	return err
+}
+
+// DoHedged is like DoWithData with hedging of each attempt: when the function
+// doesn't finish within hedgeDelay, another one is started in parallel
+// (up to maxHedges more, each after next hedgeDelay) and the first success
+// wins, the others are cancelled by their context
+//
+// The attempt fails when all started functions failed (before next hedge),
+// its error is Error of their errors (or the only error). Any unrecoverable
+// error makes the attempt unrecoverable. Failed attempt is retried by opts
+// as usual, hedges don't count as attempts. Hedges wait by WithTimer timer.
+//
+//	body, err := retry.DoHedged(ctx,
+//		func(ctx context.Context) ([]byte, error) {
+//			return fetch(ctx, url)
+//		},
+//		50*time.Millisecond, 2,
+//		retry.Attempts(3),
+//	)
+func DoHedged[T any](ctx context.Context, retryableFunc HedgedFunc[T], hedgeDelay time.Duration, maxHedges int, opts ...Option) (T, error) {
+	if maxHedges < 0 {
+		maxHedges = 0
+	}
+	f := &hedgedFunc[T]{retryableFunc: retryableFunc, hedgeDelay: hedgeDelay, maxHedges: maxHedges}
+	// the last option takes timer of the config made by opts
+	opts = append(opts[:len(opts):len(opts)], func(c *config) { f.timer = c.timer })
+	if err := do(ctx, f, opts); err != nil {
+		var zero T
+		return zero, err
+	}
+	return f.value, nil
+}
@@ -0,0 +1,124 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// manualTimer elapses when the test sends to it
+type manualTimer chan time.Time
+
+func (t manualTimer) After(time.Duration) <-chan time.Time {
+	return t
+}
+
+func TestDoHedged(t *testing.T) {
+	timer := make(manualTimer)
+	var started int32
+	cancelled := make(chan struct{})
+
+	go func() {
+		// the hedge delay elapses once
+		timer <- time.Time{}
+	}()
+	value, err := DoHedged(context.Background(),
+		func(ctx context.Context) (int, error) {
+			n := atomic.AddInt32(&started, 1)
+			if n == 1 {
+				// slow primary loses to the hedge
+				<-ctx.Done()
+				close(cancelled)
+				return 0, ctx.Err()
+			}
+			return int(n), nil
+		},
+		time.Millisecond, 2,
+		WithTimer(timer),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, value, "the first success wins")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&started))
+	<-cancelled
+
+	value, err = DoHedged(context.Background(),
+		func(ctx context.Context) (int, error) { return 42, nil },
+		time.Millisecond, 2,
+		WithTimer(make(manualTimer)),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 42, value, "no hedge of fast function")
+}
+
+func TestDoHedgedFailed(t *testing.T) {
+	timer := make(manualTimer)
+	var started int32
+	release := make(chan struct{})
+
+	go func() {
+		timer <- time.Time{}
+		timer <- time.Time{}
+	}()
+	_, err := DoHedged(context.Background(),
+		func(ctx context.Context) (int, error) {
+			if atomic.AddInt32(&started, 1) == 3 {
+				close(release)
+			}
+			// fail only once all the hedges are started
+			<-release
+			return 0, errors.New("test")
+		},
+		time.Millisecond, 2,
+		Attempts(1),
+		WithTimer(timer),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&started), "maxHedges additional attempts")
+
+	var retryErr Error
+	assert.True(t, errors.As(err, &retryErr))
+	assert.Len(t, retryErr, 1, "hedges don't count as attempts")
+	assert.Len(t, retryErr[0], 3, "errors of hedges are aggregated")
+
+	calls := 0
+	_, err = DoHedged(context.Background(),
+		func(ctx context.Context) (int, error) {
+			calls++
+			return 0, Unrecoverable(errors.New("fatal"))
+		},
+		time.Millisecond, 2,
+		WithTimer(make(manualTimer)),
+	)
+	assert.EqualError(t, err, "fatal")
+	assert.Equal(t, 1, calls, "unrecoverable error of hedge stops retrying")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = DoHedged(ctx,
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+		time.Millisecond, 1,
+		WithTimer(make(manualTimer)),
+	)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestDoHedgedSingleFlight(t *testing.T) {
+	testSingleFlight(t, 42, func(opt Option, attempt func()) int {
+		value, _ := DoHedged(context.Background(),
+			func(ctx context.Context) (int, error) {
+				attempt()
+				return 42, nil
+			},
+			time.Millisecond, 0,
+			opt,
+		)
+		return value
+	})
+}
@@ -0,0 +1,47 @@
+package retry
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithLogger logs each retry to logger at level, right before the delay
+// default is no logging
+//
+// The record has message "retry" and stable fields:
+//
+//	attempt  uint           number of the failed attempt (counted from 0, like OnRetry)
+//	error    string         error of the failed attempt
+//	delay    time.Duration  delay before the next attempt
+//	label    string         name set by WithLabel (only when set)
+//
+// log retries example:
+//
+//	retry.Do(
+//		func() error {
+//			return errors.New("some error")
+//		},
+//		retry.WithLogger(slog.Default(), slog.LevelWarn),
+//	)
+func WithLogger(logger *slog.Logger, level slog.Level) Option {
+	return func(c *config) {
+		c.logger = logger
+		c.logLevel = level
+	}
+}
+
+// logRetry logs the retry after failed attempt n
+func (c *config) logRetry(n uint, err error, delay time.Duration) {
+	if c.logger == nil || !c.logger.Enabled(c.context, c.logLevel) {
+		return
+	}
+	attrs := []slog.Attr{
+		slog.Uint64("attempt", uint64(n)),
+		slog.String("error", err.Error()),
+		slog.Duration("delay", delay),
+	}
+	if c.labelName != "" {
+		attrs = append(attrs, slog.String("label", c.labelName))
+	}
+	c.logger.LogAttrs(c.context, c.logLevel, "retry", attrs...)
+}
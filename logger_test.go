@@ -0,0 +1,51 @@
+package retry
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Delay(time.Nanosecond),
+		Units(1),
+		DelayType(LinearDelay),
+		WithLabel("db"),
+		WithLogger(logger, slog.LevelWarn),
+	)
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2, "no record after the last attempt")
+	for i, line := range lines {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, "WARN", record["level"])
+		assert.Equal(t, "retry", record["msg"])
+		assert.Equal(t, float64(i), record["attempt"])
+		assert.Equal(t, "test", record["error"])
+		assert.Equal(t, float64(i+1), record["delay"])
+		assert.Equal(t, "db", record["label"])
+	}
+
+	buf.Reset()
+	err = Do(
+		func() error { return errors.New("test") },
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithLogger(logger, slog.LevelDebug),
+	)
+	assert.Error(t, err)
+	assert.Empty(t, buf.String(), "disabled level")
+}
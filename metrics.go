@@ -0,0 +1,38 @@
+package retry
+
+import (
+	"sync/atomic"
+)
+
+// process-global counters, see RetriesInFlight and TotalRetries
+var (
+	retriesInFlight int64
+	totalRetries    int64
+)
+
+// RetriesInFlight returns count of Do calls in the whole process which
+// are retrying right now (their first attempt failed and they didn't return yet)
+//
+// It's suitable as a gauge, Do calls with GlobalMetrics(false) aren't counted.
+func RetriesInFlight() int64 {
+	return atomic.LoadInt64(&retriesInFlight)
+}
+
+// TotalRetries returns count of retries (attempts after the first one)
+// made by all Do calls in the whole process
+//
+// It's suitable as a counter, Do calls with GlobalMetrics(false) aren't counted.
+func TotalRetries() int64 {
+	return atomic.LoadInt64(&totalRetries)
+}
+
+// GlobalMetrics enables counting of Do in RetriesInFlight and TotalRetries
+// default is true
+//
+// The counters are lock-free, disable them only if even atomic operations
+// are too expensive for you.
+func GlobalMetrics(enabled bool) Option {
+	return func(c *config) {
+		c.globalMetrics = enabled
+	}
+}
@@ -0,0 +1,36 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalMetrics(t *testing.T) {
+	totalBefore := TotalRetries()
+	inFlightBefore := RetriesInFlight()
+
+	var inFlight []int64
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		BeforeAttempt(func(n uint) { inFlight = append(inFlight, RetriesInFlight()-inFlightBefore) }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []int64{0, 1, 1}, inFlight, "retrying after the first attempt")
+	assert.Equal(t, inFlightBefore, RetriesInFlight(), "not retrying after return")
+	assert.Equal(t, totalBefore+2, TotalRetries(), "retries without the first attempt")
+
+	err = Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		GlobalMetrics(false),
+		BeforeAttempt(func(n uint) { assert.Equal(t, inFlightBefore, RetriesInFlight()) }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, totalBefore+2, TotalRetries(), "disabled metrics")
+}
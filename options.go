@@ -1,6 +1,12 @@
 package retry
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand"
 	"time"
 )
 
@@ -11,17 +17,143 @@ type RetryIfFunc func(error) bool
 // n = count of attempts
 type OnRetryFunc func(n uint, err error)
 
+// Function signature of BeforeAttempt function
+// n = count of attempts
+type BeforeAttemptFunc func(n uint)
+
+// Function signature of AfterAttempt function
+// n = count of attempts
+type AfterAttemptFunc func(n uint, err error)
+
+// Function signature of OnSuccess function
+// attempts = count of calls of retryable function (1 means first try succeeded)
+type OnSuccessFunc func(attempts uint)
+
+// Function signature of error aggregator function
+// errs = errors of all attempts in order
+type ErrorAggregatorFunc func(errs []error) error
+
+// Function signature of SameError function
+// reports whether err is the same as the error of previous attempt
+type SameErrorFunc func(prev, err error) bool
+
+// Function signature of DelayType function
+// n = count of attempts, err = error of the last attempt
+type DelayTypeFunc func(n uint, err error, config *config) time.Duration
+
 type config struct {
-	attempts uint
-	delay    time.Duration
-	units    time.Duration
-	onRetry  OnRetryFunc
-	retryIf  RetryIfFunc
+	attempts      uint
+	baseDelay     time.Duration
+	delay         time.Duration
+	units         time.Duration
+	delayDuration bool
+	onRetry       OnRetryFunc
+	onSuccess     OnSuccessFunc
+	beforeAttempt BeforeAttemptFunc
+	afterAttempt  AfterAttemptFunc
+	observers     []Observer
+	retryIf       RetryIfFunc
+	delayType     DelayTypeFunc
+	aggregate     ErrorAggregatorFunc
+	context       context.Context
+	startupJitter time.Duration
+	delayChannel  chan<- time.Duration
+	lastErrorOnly bool
+
+	globalMetrics bool
+	labelName     string
+	observe       func(err error)
+
+	withoutErrorCollection bool
+
+	maxSameError int
+	sameError    SameErrorFunc
+
+	singleFlight    *Group
+	singleFlightKey string
+
+	stackTrace bool
+	stack      []uintptr
+	callerSkip int
+
+	breaker Breaker
+
+	delaySchedule []time.Duration
+	err           error
+
+	clock          Clock
+	elapsedInError bool
+	start          time.Time
+
+	perAttemptTimeout func(n uint) time.Duration
+
+	attemptTimeoutSet     bool
+	retryOnAttemptTimeout bool
+
+	maxDelay time.Duration
+
+	graceAttempts int
+	graceBudget   time.Duration
+
+	contextErrorMapper func(error) error
+
+	rand           *rand.Rand
+	randomAttempts bool
+	minAttempts    uint
+	maxAttempts    uint
+
+	settleTime time.Duration
+
+	firstRetryImmediate bool
+
+	resultCache *resultCache
+
+	timer Timer
+
+	approvePlan func(plan []PlannedDelay) bool
+
+	timestamps bool
+
+	concurrency int
+
+	resetOnProgress bool
+
+	logger   *slog.Logger
+	logLevel slog.Level
+
+	stopOnCancellation    bool
+	cancellationSentinels []error
+
+	onGiveUp OnGiveUpFunc
+
+	timeScale float64
 }
 
 // Option represents an option for retry.
 type Option func(*config)
 
+// When applies opts only when cond is true, otherwise it's a no-op
+//
+// environment specific options example:
+//
+//	retry.Do(
+//		func() error {
+//			return call()
+//		},
+//		retry.When(isProd, retry.Attempts(10)),
+//		retry.When(!isProd, retry.Attempts(2), retry.Delay(10*time.Millisecond)),
+//	)
+func When(cond bool, opts ...Option) Option {
+	return func(c *config) {
+		if !cond {
+			return
+		}
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
+
 // Attempts set count of retry
 // default is 10
 func Attempts(attempts uint) Option {
@@ -32,20 +164,49 @@ func Attempts(attempts uint) Option {
 
 // Delay set delay between retry
 // default are 1e5 units
+//
+// The delay is multiplied by Units (saturated on overflow), prefer DelayDuration.
 func Delay(delay time.Duration) Option {
 	return func(c *config) {
 		c.delay = delay
+		c.delayDuration = false
+		c.baseDelay = legacyDelay(c.delay, c.units)
 	}
 }
 
 // Units set unit of delay (probably only for tests purpose)
 // default are microsecond
+//
+// Delay(100) with Units(time.Second) is the same as DelayDuration(100 * time.Second).
+// It doesn't change delay set by DelayDuration (unless Delay follows).
 func Units(units time.Duration) Option {
 	return func(c *config) {
 		c.units = units
+		if !c.delayDuration {
+			c.baseDelay = legacyDelay(c.delay, c.units)
+		}
 	}
 }
 
+// DelayDuration set base delay between retry used by DelayType
+// default is 100ms
+//
+// It replaces Delay with Units, Units doesn't apply to it.
+func DelayDuration(delay time.Duration) Option {
+	return func(c *config) {
+		c.delayDuration = true
+		c.baseDelay = delay
+	}
+}
+
+// legacyDelay returns base delay of Delay in units, saturated on overflow
+func legacyDelay(delay, units time.Duration) time.Duration {
+	if units <= 0 {
+		return 0
+	}
+	return saturatingMul(delay, uint64(units))
+}
+
 // OnRetry function callback are called each retry
 //
 // log each retry example:
@@ -64,6 +225,86 @@ func OnRetry(onRetry OnRetryFunc) Option {
 	}
 }
 
+// BeforeAttempt function callback are called before each attempt
+//
+// select endpoint for each attempt example:
+//
+//	var endpoint string
+//	retry.Do(
+//		func() error {
+//			return call(endpoint)
+//		},
+//		retry.BeforeAttempt(func(n uint) {
+//			endpoint = endpoints[n%uint(len(endpoints))]
+//		}),
+//	)
+func BeforeAttempt(beforeAttempt BeforeAttemptFunc) Option {
+	return func(c *config) {
+		c.beforeAttempt = beforeAttempt
+	}
+}
+
+// AfterAttempt function callback are called after each failed attempt
+// right after OnRetry and before the delay, including the last attempt
+//
+// It's the place for cleanup of resources left by the failed attempt.
+//
+// remove half-written file example:
+//
+//	retry.Do(
+//		func() error {
+//			return download(url, path)
+//		},
+//		retry.AfterAttempt(func(n uint, err error) {
+//			os.Remove(path)
+//		}),
+//	)
+func AfterAttempt(afterAttempt AfterAttemptFunc) Option {
+	return func(c *config) {
+		c.afterAttempt = afterAttempt
+	}
+}
+
+// Observer observes attempts of Do, see WithObserver
+type Observer interface {
+	// BeforeAttempt is called before each attempt, right after BeforeAttempt callback
+	BeforeAttempt(n uint)
+	// AfterAttempt is called after each failed attempt, right after AfterAttempt callback
+	AfterAttempt(n uint, err error)
+}
+
+// WithObserver adds observer of attempts
+// default is no observer
+//
+// Unlike BeforeAttempt and AfterAttempt, observers don't replace each other
+// nor the callbacks, so they are meant for tooling (e.g. retrytest.Recorder)
+// which mustn't change behavior of the caller's options.
+func WithObserver(o Observer) Option {
+	return func(c *config) {
+		c.observers = append(c.observers, o)
+	}
+}
+
+// OnSuccess function callback are called once when retryable function succeed
+//
+// log flaky operations example:
+//
+//	retry.Do(
+//		func() error {
+//			return nil
+//		},
+//		retry.OnSuccess(func(attempts uint) {
+//			if attempts > 1 {
+//				log.Printf("succeeded after %d attempts\n", attempts)
+//			}
+//		}),
+//	)
+func OnSuccess(onSuccess OnSuccessFunc) Option {
+	return func(c *config) {
+		c.onSuccess = onSuccess
+	}
+}
+
 // RetryIf controls whether a retry should be attempted after an error
 // (assuming there are any retry attempts remaining)
 //
@@ -85,3 +326,538 @@ func RetryIf(retryIf RetryIfFunc) Option {
 		c.retryIf = retryIf
 	}
 }
+
+// DelayType set type of the delay between retries
+// default is FixedDelay
+func DelayType(delayType DelayTypeFunc) Option {
+	return func(c *config) {
+		c.delayType = delayType
+	}
+}
+
+// FixedDelay is a DelayType which keeps delay the same through all iterations
+func FixedDelay(_ uint, _ error, config *config) time.Duration {
+	return config.baseDelay
+}
+
+// LinearDelay is a DelayType which increases delay by its base each iteration
+func LinearDelay(n uint, _ error, config *config) time.Duration {
+	return saturatingMul(config.baseDelay, uint64(n)+1)
+}
+
+// BackOffDelay is a DelayType which doubles delay each iteration
+func BackOffDelay(n uint, _ error, config *config) time.Duration {
+	if n > 62 {
+		n = 62
+	}
+	return saturatingMul(config.baseDelay, 1<<n)
+}
+
+// FibonacciDelay is a DelayType which grows delay by Fibonacci sequence
+// (1, 1, 2, 3, 5, ... times the base)
+func FibonacciDelay(n uint, _ error, config *config) time.Duration {
+	a, b := uint64(1), uint64(1)
+	for i := uint(0); i < n && b < math.MaxInt64; i++ {
+		a, b = b, a+b
+	}
+	return saturatingMul(config.baseDelay, a)
+}
+
+// DeterministicJitter returns a DelayType which waits a random duration
+// from [0, delay] where randomness is a pure function of seed and attempt
+//
+// The same seed always produces the same sequence of delays, regardless of
+// wall-clock or goroutine scheduling (useful for reproducible simulations).
+func DeterministicJitter(seed int64) DelayTypeFunc {
+	return func(n uint, _ error, config *config) time.Duration {
+		max := config.baseDelay
+		if max <= 0 {
+			return 0
+		}
+		return time.Duration(mix(uint64(seed), uint64(n)) % (uint64(max) + 1))
+	}
+}
+
+// mix hashes seed and n by SplitMix64 finalizer
+func mix(seed, n uint64) uint64 {
+	x := seed ^ (n+1)*0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// JitterWhen returns a DelayType which waits the delay plus a random duration
+// from [0, jitter] when the last error matches pred, otherwise just the delay
+//
+// It spreads only retries where spreading matters (e.g. lock or rate limit
+// contention) and keeps the plain delay for the others (e.g. timeouts).
+//
+//	retry.DelayType(retry.JitterWhen(
+//		func(err error) bool { return errors.Is(err, ErrTooManyRequests) },
+//		time.Second,
+//	))
+func JitterWhen(pred func(err error) bool, jitter time.Duration) DelayTypeFunc {
+	return func(_ uint, err error, config *config) time.Duration {
+		delay := config.baseDelay
+		if jitter <= 0 || err == nil || !pred(err) {
+			return delay
+		}
+		return saturatingAdd(delay, config.jitter(jitter))
+	}
+}
+
+// RampedBackoff returns a DelayType which grows delay exponentially from start
+// so it reaches cap at attempt attemptsToReachCap and stays capped after that
+//
+// It computes the factor of backoff, so "ramp from 100ms to 30s over 6 attempts" is
+//
+//	retry.DelayType(retry.RampedBackoff(100*time.Millisecond, 30*time.Second, 6))
+//
+// Delay and Units options are ignored.
+func RampedBackoff(start, cap time.Duration, attemptsToReachCap uint) DelayTypeFunc {
+	return func(n uint, _ error, _ *config) time.Duration {
+		if n >= attemptsToReachCap || start <= 0 || start >= cap {
+			return cap
+		}
+		factor := math.Pow(float64(cap)/float64(start), float64(n)/float64(attemptsToReachCap))
+		return time.Duration(math.Round(float64(start) * factor))
+	}
+}
+
+// saturatingMul multiplies delay by factor, overflow results in maximal duration
+func saturatingMul(delay time.Duration, factor uint64) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	if factor > uint64(math.MaxInt64/int64(delay)) {
+		return math.MaxInt64
+	}
+	return delay * time.Duration(factor)
+}
+
+// saturatingAdd adds non-negative jitter to delay, overflow results in maximal duration
+func saturatingAdd(delay, jitter time.Duration) time.Duration {
+	if delay > math.MaxInt64-jitter {
+		return math.MaxInt64
+	}
+	return delay + jitter
+}
+
+// jitter returns random duration from [0, max] by WithRand generator,
+// math.MaxInt64 is never returned as [0, max] doesn't fit into int63n
+func (c *config) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	if max == math.MaxInt64 {
+		return time.Duration(c.int63n(math.MaxInt64))
+	}
+	return time.Duration(c.int63n(int64(max) + 1))
+}
+
+// ConstantJitterDelay returns a DelayType which waits base plus a random
+// duration from [0, jitter] before every retry
+//
+// The delay doesn't grow with attempts, so it only spreads retries of many
+// clients in time (avoids thundering herd) without any backoff.
+// Delay and Units options are ignored.
+func ConstantJitterDelay(base, jitter time.Duration) DelayTypeFunc {
+	return func(_ uint, _ error, config *config) time.Duration {
+		if jitter <= 0 {
+			return base
+		}
+		return saturatingAdd(base, config.jitter(jitter))
+	}
+}
+
+// LastErrorOnly makes Do return the last error instead of all errors
+// default is false (return Error)
+//
+// The returned error is exactly the instance returned by retryable function
+// (or the context error), so it can be compared by identity
+// (e.g. in errgroup), WithLabel and WithTimestamps don't wrap it.
+// Only WithElapsedInError and WithStackTrace wrap it (they are explicit
+// requests for a decorated error). It is preferred over WithErrorAggregator.
+func LastErrorOnly(lastErrorOnly bool) Option {
+	return func(c *config) {
+		c.lastErrorOnly = lastErrorOnly
+	}
+}
+
+// WithoutErrorCollection makes Do keep only the last error instead of
+// collecting errors of all attempts, so failed attempts don't allocate
+// default is false, it's implied by LastErrorOnly
+//
+// Error returned by Do (or passed to WithErrorAggregator) contains the last error only.
+func WithoutErrorCollection(without bool) Option {
+	return func(c *config) {
+		c.withoutErrorCollection = without
+	}
+}
+
+// WithErrorAggregator set function which produces error returned by Do
+// from errors of all attempts
+// default returns them as Error
+//
+// join errors example:
+//
+//	retry.Do(
+//		func() error {
+//			return errors.New("some error")
+//		},
+//		retry.WithErrorAggregator(errors.Join),
+//	)
+func WithErrorAggregator(aggregate ErrorAggregatorFunc) Option {
+	return func(c *config) {
+		c.aggregate = aggregate
+	}
+}
+
+// Context allow to set context of retry
+// default are Background context
+//
+// Do stops waiting and returns immediately when the context is done,
+// context error is the last error of the result.
+//
+// example of immediately cancellation (maybe it isn't the best example, but it describes behavior enough; I hope)
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	cancel()
+//
+//	retry.Do(
+//		func() error {
+//			...
+//		},
+//		retry.Context(ctx),
+//	)
+func Context(ctx context.Context) Option {
+	return func(c *config) {
+		c.context = ctx
+	}
+}
+
+// WithContextErrorMapper set function which maps error of Context
+// before it's collected (e.g. to a domain specific error)
+// default keeps the error of Context
+//
+// Wrap the original error (e.g. with %w) to keep errors.Is(err, context.Canceled) working.
+//
+//	retry.WithContextErrorMapper(func(err error) error {
+//		return fmt.Errorf("%w: %w", ErrShuttingDown, err)
+//	})
+func WithContextErrorMapper(mapper func(error) error) Option {
+	return func(c *config) {
+		c.contextErrorMapper = mapper
+	}
+}
+
+// WithStartupJitter set maximum of random delay before the first attempt
+// default is zero (the first attempt is immediate)
+//
+// It spreads load of many instances doing the same call at the same time
+// (e.g. on start). The waiting respects Context.
+func WithStartupJitter(max time.Duration) Option {
+	return func(c *config) {
+		c.startupJitter = max
+	}
+}
+
+// WithDelayChannel set channel which receives each delay before Do waits for it
+//
+// Sending is best-effort, the delay is dropped when nobody is ready
+// to receive it, so the channel never blocks the retry loop
+// (use buffered channel to not miss delays).
+func WithDelayChannel(ch chan<- time.Duration) Option {
+	return func(c *config) {
+		c.delayChannel = ch
+	}
+}
+
+// WithLabel prefixes each collected error by name of operation
+// (as "name: error") so the result of Do is self-identifying in logs
+//
+// Prefixed errors still unwrap to the original ones (errors.Is and errors.As work).
+// Callbacks receive original errors.
+func WithLabel(name string) Option {
+	return func(c *config) {
+		c.labelName = name
+	}
+}
+
+// MaxConsecutiveSameError aborts retrying when the same error occurs
+// n times in a row, even when it's retryable
+// default is 0 (disabled)
+//
+// Errors are compared by errors.Is by default (see SameError).
+// Together with Attempts, whichever limit triggers first wins.
+func MaxConsecutiveSameError(n int) Option {
+	return func(c *config) {
+		c.maxSameError = n
+	}
+}
+
+// SameError set function which compares consecutive errors for MaxConsecutiveSameError
+// default is errors.Is(err, prev)
+//
+// compare by message example:
+//
+//	retry.SameError(func(prev, err error) bool {
+//		return prev.Error() == err.Error()
+//	})
+func SameError(sameError SameErrorFunc) Option {
+	return func(c *config) {
+		c.sameError = sameError
+	}
+}
+
+// MaxDelay set maximum delay between retries
+// default is no maximum
+func MaxDelay(maxDelay time.Duration) Option {
+	return func(c *config) {
+		c.maxDelay = maxDelay
+	}
+}
+
+// WithSettleTime set time to wait after failed attempt which is retried,
+// before the delay to the next attempt
+// default is zero
+//
+// It's meant for eventually consistent systems, where state read right
+// after a write is stale, so the system has time to settle before the next
+// attempt. It's independent of the delay between attempts (it isn't affected
+// by DelayType, MaxDelay, WithTimeScale) and respects Context. The error
+// is evaluated (RetryIf, breaker, ...) before the settle time, so Do never
+// waits after the attempt which ends retrying.
+func WithSettleTime(settleTime time.Duration) Option {
+	return func(c *config) {
+		c.settleTime = settleTime
+	}
+}
+
+// FirstRetryImmediate makes the first retry immediate
+// default is false
+//
+// Attempts are numbered from 0: there is no delay between attempt 0 and 1,
+// delay between attempt n and n+1 (for n >= 1) is still computed for n
+// by DelayType (or WithDelaySchedule).
+func FirstRetryImmediate(immediate bool) Option {
+	return func(c *config) {
+		c.firstRetryImmediate = immediate
+	}
+}
+
+// config errors of options, an option clears its own error when
+// a later one sets a valid value, see clearError
+var (
+	errEmptyDelaySchedule           = errors.New("retry: empty delay schedule")
+	errNonPositivePerAttemptTimeout = errors.New("retry: non-positive per-attempt timeout")
+	errRandomAttempts               = errors.New("retry: random attempts")
+	errNonPositiveTimeScale         = errors.New("retry: non-positive time scale")
+	errUnknownBackoff               = errors.New("retry: unknown backoff type")
+)
+
+// clearError clears config error which is (or wraps) err,
+// so a valid value of an option overrides its invalid value set before
+func (c *config) clearError(err error) {
+	if errors.Is(c.err, err) {
+		c.err = nil
+	}
+}
+
+// WithDelaySchedule set explicit delays between attempts, it overrides DelayType
+//
+// The delay after attempt i is delays[i], the last delay repeats when
+// the schedule is exhausted (retrying continues up to Attempts).
+// Empty schedule is an error returned by Do (a later valid schedule
+// overrides it). delays are copied, the caller may reuse the slice.
+//
+//	retry.Do(
+//		func() error {
+//			...
+//		},
+//		retry.WithDelaySchedule([]time.Duration{
+//			100 * time.Millisecond,
+//			time.Second,
+//			5 * time.Second,
+//		}),
+//	)
+func WithDelaySchedule(delays []time.Duration) Option {
+	delays = append([]time.Duration(nil), delays...)
+	return func(c *config) {
+		if len(delays) == 0 {
+			c.err = errEmptyDelaySchedule
+			return
+		}
+		c.clearError(errEmptyDelaySchedule)
+		c.delaySchedule = delays
+	}
+}
+
+// WithElapsedInError wraps error returned on final failure by message
+// with count of attempts and total elapsed time
+// (e.g. "retry: failed after 4 attempts over 6.2s: connection refused")
+// default is false
+//
+// The time is measured by Clock, the wrapped error unwraps to the error
+// which would be returned otherwise.
+func WithElapsedInError(enabled bool) Option {
+	return func(c *config) {
+		c.elapsedInError = enabled
+	}
+}
+
+// WithPerAttemptTimeout set timeout of each attempt made by DoContext
+// default is no timeout (attempts are bound only by Context)
+//
+// Context of each attempt is a child of Context with the timeout,
+// non-positive timeout is an error returned by Do (a later valid timeout
+// overrides it).
+func WithPerAttemptTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		if timeout <= 0 {
+			c.err = errNonPositivePerAttemptTimeout
+			return
+		}
+		c.clearError(errNonPositivePerAttemptTimeout)
+		c.perAttemptTimeout = func(uint) time.Duration { return timeout }
+	}
+}
+
+// WithPerAttemptTimeoutFunc set timeout of attempt n made by DoContext
+// as a function of attempt number (e.g. short first tries to fail fast)
+//
+// Non-positive timeout means attempt n has no own timeout (it's bound only by Context).
+//
+//	retry.WithPerAttemptTimeoutFunc(func(n uint) time.Duration {
+//		return time.Duration(n+1) * time.Second
+//	})
+func WithPerAttemptTimeoutFunc(timeout func(n uint) time.Duration) Option {
+	return func(c *config) {
+		c.clearError(errNonPositivePerAttemptTimeout)
+		c.perAttemptTimeout = timeout
+	}
+}
+
+// RetryOnPerAttemptTimeout set whether an attempt which failed on its own
+// deadline is retried, regardless of RetryIf
+// default is to leave the decision to RetryIf
+//
+// The error of the attempt is a deadline when it is (or wraps)
+// context.DeadlineExceeded. It's told apart by context which was cancelled:
+// when Context itself is done, it's the deadline of the whole Do and it's
+// never retried; otherwise the deadline came from a per-attempt child context
+// (WithPerAttemptTimeout or a timeout made by the function itself) and
+// it's retried when retry is true.
+func RetryOnPerAttemptTimeout(retry bool) Option {
+	return func(c *config) {
+		c.attemptTimeoutSet = true
+		c.retryOnAttemptTimeout = retry
+	}
+}
+
+// retryable reports whether err of an attempt should be retried
+func (c *config) retryable(err error) bool {
+	if c.attemptTimeoutSet && errors.Is(err, context.DeadlineExceeded) {
+		if c.context.Err() != nil {
+			return false
+		}
+		return c.retryOnAttemptTimeout
+	}
+	if c.stopOnCancellation && IsCancellation(err, c.cancellationSentinels...) {
+		return false
+	}
+	return c.retryIf(err)
+}
+
+// WithGraceAttempts makes Do continue with up to n more attempts within
+// budget after Context is done (e.g. best-effort cleanup during shutdown)
+// default is no grace attempts
+//
+// It intentionally ignores cancellation of Context for a short time:
+// when Context is done while waiting between attempts, the waiting ends and
+// the grace attempts run with a context detached from Context cancellation
+// (keeping its values) and limited by budget. Limit of Attempts still applies.
+// When the budget is exhausted, the error of Context is the last error.
+func WithGraceAttempts(n int, budget time.Duration) Option {
+	return func(c *config) {
+		c.graceAttempts = n
+		c.graceBudget = budget
+	}
+}
+
+// WithRand set random number generator used by Do and its delay types
+// (e.g. by jitters and RandomAttempts)
+// default is the global generator of math/rand
+//
+// A seeded generator makes random behavior reproducible.
+// *rand.Rand isn't safe for concurrent use, don't share it between concurrent Do calls.
+func WithRand(r *rand.Rand) Option {
+	return func(c *config) {
+		c.rand = r
+	}
+}
+
+// RandomAttempts set count of attempts to random value from [min, max]
+// picked at the start of each Do call (by WithRand generator)
+// It's meant for fuzzing of code around retries.
+// Zero min (it would mean unlimited attempts), min greater than max
+// or range wider than math.MaxInt64 is an error returned by Do
+// (a later valid range overrides it).
+func RandomAttempts(min, max uint) Option {
+	return func(c *config) {
+		if min == 0 {
+			c.err = fmt.Errorf("%w min is zero", errRandomAttempts)
+			return
+		}
+		if min > max {
+			c.err = fmt.Errorf("%w min is greater than max", errRandomAttempts)
+			return
+		}
+		if uint64(max-min) >= math.MaxInt64 {
+			c.err = fmt.Errorf("%w range is too wide", errRandomAttempts)
+			return
+		}
+		c.clearError(errRandomAttempts)
+		c.randomAttempts = true
+		c.minAttempts = min
+		c.maxAttempts = max
+	}
+}
+
+// int63n returns random number from [0, n) by WithRand generator
+func (c *config) int63n(n int64) int64 {
+	if c == nil || c.rand == nil {
+		return rand.Int63n(n)
+	}
+	return c.rand.Int63n(n)
+}
+
+// WithTimestamps records time (by Clock) when each collected error occurred,
+// see Error.TimedErrors
+// default is false (errors are collected as they are)
+//
+// It helps to correlate failed attempts with external events,
+// timestamped errors unwrap to the original ones.
+func WithTimestamps(enabled bool) Option {
+	return func(c *config) {
+		c.timestamps = enabled
+	}
+}
+
+// WithTimeScale multiplies each delay between attempts by factor
+// (after DelayType, jitter and MaxDelay), e.g. 0.1 runs the schedule 10x faster
+// default is 1 (no scaling)
+//
+// It's handy for running production policy in load tests,
+// non-positive factor is an error returned by Do (a later valid factor
+// overrides it).
+func WithTimeScale(factor float64) Option {
+	return func(c *config) {
+		if !(factor > 0) {
+			c.err = errNonPositiveTimeScale
+			return
+		}
+		c.clearError(errNonPositiveTimeScale)
+		c.timeScale = factor
+	}
+}
@@ -0,0 +1,84 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrPlanRejected is returned by Do when WithPlanApproval rejects the plan
+var ErrPlanRejected = errors.New("retry: plan rejected")
+
+// PlannedDelay is a delay of the retry plan, see WithPlanApproval
+type PlannedDelay struct {
+	// Delay before the next attempt
+	Delay time.Duration
+	// Approximate marks random delay (e.g. jitter), the real delay may differ
+	Approximate bool
+}
+
+// WithPlanApproval set function which approves planned delays between
+// attempts before the first attempt, Do returns ErrPlanRejected without
+// any attempt when it returns false (e.g. for audit in regulated environments)
+//
+// The plan has a delay for each retry and is computed by the configured
+// delay options as if all attempts failed with nil error. Delays depending
+// on randomness are marked as approximate, delays of stateful delay types
+// (e.g. AdaptiveDelay) are valid for the current state only. Delays
+// depending on the error (e.g. JitterWhen) are planned for nil error and
+// they aren't marked as approximate, the real delays may differ.
+// Plan approval with unlimited attempts or with more than 10000 attempts
+// (the plan would be too big) is an error returned by Do.
+//
+//	retry.WithPlanApproval(func(plan []retry.PlannedDelay) bool {
+//		log.Printf("retry plan: %v", plan)
+//		return true
+//	})
+func WithPlanApproval(approve func(plan []PlannedDelay) bool) Option {
+	return func(c *config) {
+		c.approvePlan = approve
+	}
+}
+
+// maximal Attempts of plan approval, see WithPlanApproval
+const maxPlanAttempts = 10000
+
+// plan computes delays before each retry
+func (c *config) plan() []PlannedDelay {
+	configured := c.rand
+	defer func() { c.rand = configured }()
+
+	// delays which differ with different generators depend on randomness
+	first := rand.New(rand.NewSource(1))
+	second := rand.New(rand.NewSource(2))
+
+	plan := make([]PlannedDelay, 0, c.attempts-1)
+	for n := uint(0); n+1 < c.attempts; n++ {
+		c.rand = first
+		delay := c.delayFor(n, nil)
+		c.rand = second
+		plan = append(plan, PlannedDelay{
+			Delay:       delay,
+			Approximate: delay != c.delayFor(n, nil),
+		})
+	}
+	return plan
+}
+
+// approve asks for approval of the plan when WithPlanApproval is set
+func (c *config) approve() error {
+	if c.approvePlan == nil {
+		return nil
+	}
+	if c.attempts == 0 {
+		return errors.New("retry: plan approval requires limited attempts")
+	}
+	if c.attempts > maxPlanAttempts {
+		return fmt.Errorf("retry: plan approval allows at most %d attempts", maxPlanAttempts)
+	}
+	if !c.approvePlan(c.plan()) {
+		return ErrPlanRejected
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPlanApproval(t *testing.T) {
+	var plan []PlannedDelay
+	var calls int
+	err := Do(
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		Attempts(4),
+		Delay(time.Microsecond),
+		Units(1),
+		DelayType(BackOffDelay),
+		FirstRetryImmediate(true),
+		WithPlanApproval(func(p []PlannedDelay) bool {
+			plan = p
+			return true
+		}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 4, calls, "approved plan runs")
+	assert.Equal(t, []PlannedDelay{
+		{Delay: 0},
+		{Delay: 2 * time.Microsecond},
+		{Delay: 4 * time.Microsecond},
+	}, plan, "delay before each retry")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		WithPlanApproval(func(p []PlannedDelay) bool { return false }),
+	)
+	assert.Equal(t, ErrPlanRejected, err)
+	assert.Equal(t, 0, calls, "no attempt of rejected plan")
+
+	err = Do(
+		func() error { return nil },
+		Attempts(0),
+		WithPlanApproval(func(p []PlannedDelay) bool { return true }),
+	)
+	assert.EqualError(t, err, "retry: plan approval requires limited attempts")
+}
+
+func TestWithPlanApprovalApproximate(t *testing.T) {
+	var plan []PlannedDelay
+	err := Do(
+		func() error { return nil },
+		Attempts(3),
+		DelayType(ConstantJitterDelay(time.Second, time.Second)),
+		WithPlanApproval(func(p []PlannedDelay) bool {
+			plan = p
+			return true
+		}),
+	)
+	assert.NoError(t, err)
+	assert.Len(t, plan, 2)
+	for _, delay := range plan {
+		assert.True(t, delay.Approximate, "jitter is approximate")
+		assert.True(t, delay.Delay >= time.Second && delay.Delay <= 2*time.Second)
+	}
+
+	err = Do(
+		func() error { return nil },
+		Attempts(3),
+		DelayType(DeterministicJitter(42)),
+		WithPlanApproval(func(p []PlannedDelay) bool {
+			plan = p
+			return true
+		}),
+	)
+	assert.NoError(t, err)
+	assert.False(t, plan[0].Approximate, "deterministic jitter is exact")
+}
+
+func TestWithPlanApprovalManyAttempts(t *testing.T) {
+	var planned int
+	approve := WithPlanApproval(func(p []PlannedDelay) bool {
+		planned = len(p)
+		return false
+	})
+
+	err := Do(func() error { return nil }, Attempts(maxPlanAttempts), approve)
+	assert.Equal(t, ErrPlanRejected, err)
+	assert.Equal(t, maxPlanAttempts-1, planned)
+
+	calls := 0
+	planned = 0
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		Attempts(1<<40),
+		approve,
+	)
+	assert.EqualError(t, err, "retry: plan approval allows at most 10000 attempts")
+	assert.Equal(t, 0, planned, "no plan is computed")
+	assert.Equal(t, 0, calls)
+}
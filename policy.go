@@ -0,0 +1,36 @@
+package retry
+
+// Policy is a named bundle of options defined once and applied by WithPolicy
+//
+//	var HTTPPolicy = retry.NewPolicy(
+//		retry.Attempts(5),
+//		retry.DelayType(retry.BackOffDelay),
+//		retry.RetryIf(isTransientHTTPError),
+//	)
+//
+//	retry.Do(fn, retry.WithPolicy(HTTPPolicy), retry.Attempts(3))
+type Policy struct {
+	opts []Option
+}
+
+// NewPolicy returns a Policy with the options
+func NewPolicy(opts ...Option) Policy {
+	return Policy{opts: append([]Option(nil), opts...)}
+}
+
+// With returns a copy of Policy with opts applied on top of its options
+func (p Policy) With(opts ...Option) Policy {
+	all := make([]Option, 0, len(p.opts)+len(opts))
+	all = append(all, p.opts...)
+	return Policy{opts: append(all, opts...)}
+}
+
+// WithPolicy applies options of the policy at its place among options,
+// so options after it override the policy defaults
+func WithPolicy(p Policy) Option {
+	return func(c *config) {
+		for _, opt := range p.opts {
+			opt(c)
+		}
+	}
+}
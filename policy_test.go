@@ -0,0 +1,37 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPolicy(t *testing.T) {
+	policy := NewPolicy(Attempts(4), Units(time.Nanosecond))
+
+	run := func(opts ...Option) int {
+		calls := 0
+		Do(
+			func() error {
+				calls++
+				return errors.New("test")
+			},
+			opts...,
+		)
+		return calls
+	}
+
+	assert.Equal(t, 4, run(WithPolicy(policy)))
+	assert.Equal(t, 2, run(WithPolicy(policy), Attempts(2)), "per-call option overrides the policy")
+	assert.Equal(t, 4, run(Attempts(2), WithPolicy(policy)), "policy overrides earlier options")
+
+	strict := policy.With(Attempts(1))
+	assert.Equal(t, 1, run(WithPolicy(strict)))
+	assert.Equal(t, 4, run(WithPolicy(policy)), "the original policy is unchanged")
+
+	value, err := DoWithData(func() (int, error) { return 42, nil }, WithPolicy(policy))
+	assert.NoError(t, err)
+	assert.Equal(t, 42, value)
+}
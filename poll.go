@@ -0,0 +1,53 @@
+package retry
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotReady is collected for attempts of PollWithData which aren't done yet
+var ErrNotReady = errors.New("not ready")
+
+// PollWithData calls fn until it reports done (e.g. resource is ready)
+// and returns its value
+//
+// Attempt which isn't done and has no error is retried always (as ErrNotReady),
+// attempt with error is retried by RetryIf. The polling respects ctx.
+//
+// The value of the last call is returned even on failure (e.g. when ctx
+// is done or attempts are exhausted), so the caller may inspect the last
+// known state.
+//
+//	status, err := retry.PollWithData(ctx,
+//		func(ctx context.Context) (string, bool, error) {
+//			status, err := job.Status(ctx)
+//			return status, status == "finished", err
+//		},
+//		retry.Attempts(0),
+//	)
+func PollWithData[T any](ctx context.Context, fn func(context.Context) (T, bool, error), opts ...Option) (T, error) {
+	var last T
+	err := Do(
+		func() error {
+			value, done, err := fn(ctx)
+			last = value
+			if err != nil {
+				return err
+			}
+			if !done {
+				return ErrNotReady
+			}
+			return nil
+		},
+		append(append([]Option{}, opts...), Context(ctx), retryNotReady, skipCallers(1))...,
+	)
+	return last, err
+}
+
+// retryNotReady makes RetryIf retry ErrNotReady always
+func retryNotReady(c *config) {
+	retryIf := c.retryIf
+	c.retryIf = func(err error) bool {
+		return err == ErrNotReady || retryIf(err)
+	}
+}
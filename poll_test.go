@@ -0,0 +1,47 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollWithData(t *testing.T) {
+	var calls int
+	value, err := PollWithData(context.Background(),
+		func(ctx context.Context) (int, bool, error) {
+			calls++
+			if calls == 2 {
+				return 0, false, errors.New("test")
+			}
+			return calls, calls == 4, nil
+		},
+		Units(time.Nanosecond),
+		RetryIf(func(err error) bool { return err.Error() == "test" }),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, value, "value of done call")
+}
+
+func TestPollWithDataCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var calls int
+	value, err := PollWithData(ctx,
+		func(ctx context.Context) (int, bool, error) {
+			calls++
+			return calls, false, nil
+		},
+		Attempts(0),
+		Delay(time.Millisecond),
+		Units(1),
+	)
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err.(Error)[len(err.(Error))-1], context.DeadlineExceeded), "context error is the last one")
+	assert.True(t, errors.Is(err.(Error)[0], ErrNotReady))
+	assert.Equal(t, calls, value, "value of the last call")
+}
@@ -0,0 +1,73 @@
+package retry
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// maximal count of attempts of DoWithProgress with ResetAttemptsOnProgress
+// as a multiple of Attempts
+const progressAttemptsFactor = 10
+
+// Function signature of retryable function which reports its progress
+// by calling progress (e.g. after each uploaded chunk)
+type RetryableFuncWithProgress func(progress func()) error
+
+type progressFunc struct {
+	retryableFunc RetryableFuncWithProgress
+	progressed    atomic.Bool
+}
+
+func (f *progressFunc) call(_ context.Context) error {
+	f.progressed.Store(false)
+	return f.retryableFunc(f.progress)
+}
+
+func (f *progressFunc) progress() {
+	f.progressed.Store(true)
+}
+
+// DoWithProgress is like Do for long running function which reports its
+// forward movement (e.g. resumable upload), see ResetAttemptsOnProgress
+//
+//	retry.DoWithProgress(
+//		func(progress func()) error {
+//			for offset < size {
+//				if err := upload(offset); err != nil {
+//					return err
+//				}
+//				offset += chunk
+//				progress()
+//			}
+//			return nil
+//		},
+//		retry.ResetAttemptsOnProgress(true),
+//	)
+func DoWithProgress(retryableFunc RetryableFuncWithProgress, opts ...Option) error {
+	return do(context.Background(), &progressFunc{retryableFunc: retryableFunc}, opts)
+}
+
+// ResetAttemptsOnProgress makes failed attempt of DoWithProgress which
+// reported progress not count against Attempts: the attempt counter
+// (and so the backoff) starts again from 0
+// default is false
+//
+// To prevent livelock of a function which makes progress forever, there is
+// an absolute cap of 10 times Attempts on count of all attempts
+// (no cap with unlimited attempts).
+func ResetAttemptsOnProgress(reset bool) Option {
+	return func(c *config) {
+		c.resetOnProgress = reset
+	}
+}
+
+// progressed reports whether the last attempt reported progress and
+// may reset the attempt counter after calls attempts
+func (c *config) progressed(retryableFunc retryable, calls uint) bool {
+	f, ok := retryableFunc.(*progressFunc)
+	if !ok || !c.resetOnProgress || !f.progressed.Load() {
+		return false
+	}
+	// the attempts after the reset have to fit the cap
+	return c.attempts == 0 || calls+c.attempts-1 <= c.attempts*progressAttemptsFactor
+}
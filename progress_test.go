@@ -0,0 +1,67 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDoWithProgress(t *testing.T) {
+	const size = 10
+	var offset, calls int
+	err := DoWithProgress(
+		func(progress func()) error {
+			calls++
+			// each attempt uploads 2 chunks and fails
+			for i := 0; i < 2 && offset < size; i++ {
+				offset++
+				progress()
+			}
+			if offset < size {
+				return errors.New("connection reset")
+			}
+			return nil
+		},
+		Attempts(2),
+		Units(time.Nanosecond),
+		ResetAttemptsOnProgress(true),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, size, offset)
+	assert.Equal(t, 5, calls, "attempts with progress don't count")
+
+	offset, calls = 0, 0
+	err = DoWithProgress(
+		func(progress func()) error {
+			calls++
+			offset++
+			progress()
+			return errors.New("connection reset")
+		},
+		Attempts(2),
+		Units(time.Nanosecond),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls, "progress counts by default")
+}
+
+func TestDoWithProgressAbsoluteCap(t *testing.T) {
+	var calls uint
+	var attempts []uint
+	err := DoWithProgress(
+		func(progress func()) error {
+			calls++
+			progress()
+			return errors.New("test")
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		ResetAttemptsOnProgress(true),
+		OnRetry(func(n uint, err error) { attempts = append(attempts, n) }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, uint(3*progressAttemptsFactor), calls, "absolute cap prevents livelock")
+	assert.Equal(t, uint(0), attempts[0], "attempt counter is reset")
+}
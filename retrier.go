@@ -0,0 +1,37 @@
+package retry
+
+// Retrier is a reusable retry policy
+//
+// It applies its options on each Do call, so state kept by stateful options
+// (e.g. AdaptiveDelay) is shared across the calls.
+// Retrier is safe for concurrent use when its options are.
+type Retrier struct {
+	opts []Option
+}
+
+// New returns a Retrier with the options
+func New(opts ...Option) *Retrier {
+	return &Retrier{opts: opts}
+}
+
+// Do is like package Do with options of Retrier,
+// opts are applied on top of them
+func (r *Retrier) Do(retryableFunc RetryableFunc, opts ...Option) error {
+	return Do(retryableFunc, append(r.options(opts), skipCallers(1))...)
+}
+
+// With returns a copy of Retrier with opts applied on top of its options,
+// the original Retrier is unchanged
+//
+//	client := retry.New(retry.Attempts(5), retry.Delay(100))
+//	slowService := client.With(retry.Delay(1000))
+func (r *Retrier) With(opts ...Option) *Retrier {
+	return &Retrier{opts: r.options(opts)}
+}
+
+// options returns options of Retrier followed by opts
+func (r *Retrier) options(opts []Option) []Option {
+	all := make([]Option, 0, len(r.opts)+len(opts))
+	all = append(all, r.opts...)
+	return append(all, opts...)
+}
@@ -0,0 +1,49 @@
+package retry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetrier(t *testing.T) {
+	var retryCount uint
+	r := New(
+		Attempts(3),
+		Units(time.Nanosecond),
+		OnRetry(func(n uint, err error) { retryCount++ }),
+	)
+
+	err := r.Do(func() error { return errors.New("test") })
+	assert.Error(t, err)
+	assert.Equal(t, uint(3), retryCount, "options of retrier")
+
+	retryCount = 0
+	err = r.Do(func() error { return errors.New("test") }, Attempts(2))
+	assert.Error(t, err)
+	assert.Equal(t, uint(2), retryCount, "call options override retrier options")
+}
+
+func TestRetrierWith(t *testing.T) {
+	var retryCount uint
+	base := New(
+		Attempts(3),
+		Units(time.Nanosecond),
+		OnRetry(func(n uint, err error) { retryCount++ }),
+	)
+	derived := base.With(Attempts(5))
+
+	assert.Error(t, derived.Do(func() error { return errors.New("test") }))
+	assert.Equal(t, uint(5), retryCount, "override of derived retrier")
+
+	retryCount = 0
+	assert.Error(t, base.Do(func() error { return errors.New("test") }))
+	assert.Equal(t, uint(3), retryCount, "base retrier isn't mutated")
+
+	base.With(Attempts(1)).With(Attempts(2))
+	retryCount = 0
+	assert.Error(t, derived.Do(func() error { return errors.New("test") }))
+	assert.Equal(t, uint(5), retryCount, "derived retrier isn't mutated by its siblings")
+}
@@ -3,7 +3,7 @@ Simple library for retry mechanism
 
 slightly inspired by [Try::Tiny::Retry](https://metacpan.org/pod/Try::Tiny::Retry)
 
-SYNOPSIS
+# SYNOPSIS
 
 http get with retry:
 
@@ -30,8 +30,7 @@ http get with retry:
 
 [next examples](https://github.com/avast/retry-go/tree/master/examples)
 
-
-SEE ALSO
+# SEE ALSO
 
 * [giantswarm/retry-go](https://github.com/giantswarm/retry-go) - slightly complicated interface.
 
@@ -43,74 +42,579 @@ SEE ALSO
 
 * [matryer/try](https://github.com/matryer/try) - very popular package, nonintuitive interface (for me)
 
-BREAKING CHANGES
+# BREAKING CHANGES
 
 0.3.0 -> 1.0.0
 
 * `retry.Retry` function are changed to `retry.Do` function
 
 * `retry.RetryCustom` (OnRetry) and `retry.RetryCustomWithOpts` functions are now implement via functions produces Options (aka `retry.OnRetry`)
-
-
 */
 package retry
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Function signature of retryable function
 type RetryableFunc func() error
 
+// Function signature of retryable function with context of the attempt
+type RetryableContextFunc func(ctx context.Context) error
+
+// Stop is used as a return value from RetryableFunc to indicate that
+// the retry loop should stop without any further attempt.
+// Do returns nil in that case, the stop is intentional, not a failure.
+// (use Unrecoverable for stopping with failure)
+var Stop = errors.New("stop retry")
+
+// retryable is a retryable function of any signature
+type retryable interface {
+	call(ctx context.Context) error
+}
+
+func (f RetryableFunc) call(_ context.Context) error {
+	return f()
+}
+
+func (f RetryableContextFunc) call(ctx context.Context) error {
+	return f(ctx)
+}
+
 func Do(retryableFunc RetryableFunc, opts ...Option) error {
-	var n uint
+	return do(context.Background(), retryableFunc, opts)
+}
+
+// Retryable is a stateful retry subject, its state is kept across attempts
+// (e.g. cursor which advances), see DoRetryable
+type Retryable interface {
+	// Attempt makes one attempt
+	Attempt() error
+}
+
+// DoRetryable is like Do for object implementing Retryable
+//
+//	type pager struct{ cursor string }
+//
+//	func (p *pager) Attempt() error {
+//		next, err := fetch(p.cursor)
+//		if err != nil {
+//			return err
+//		}
+//		p.cursor = next
+//		return nil
+//	}
+//
+//	err := retry.DoRetryable(&pager{})
+func DoRetryable(r Retryable, opts ...Option) error {
+	return do(context.Background(), RetryableFunc(r.Attempt), opts)
+}
+
+// Function signature of retryable function with data
+type RetryableFuncWithData[T any] func() (T, error)
+
+// resultHolder is a retryable function which keeps its result,
+// see DoWithData
+type resultHolder interface {
+	result() interface{}
+	// setResult sets result, it reports false for value of other type
+	setResult(value interface{}) bool
+}
+
+type dataFunc[T any] struct {
+	retryableFunc RetryableFuncWithData[T]
+	value         T
+}
+
+func (f *dataFunc[T]) call(_ context.Context) error {
+	value, err := f.retryableFunc()
+	if err == nil {
+		f.value = value
+	}
+	return err
+}
+
+func (f *dataFunc[T]) result() interface{} {
+	return f.value
+}
+
+func (f *dataFunc[T]) setResult(value interface{}) bool {
+	v, ok := value.(T)
+	if ok {
+		f.value = v
+	}
+	return ok
+}
+
+// DoWithData is like Do for function which returns data,
+// it returns data of the successful attempt (zero value on failure)
+//
+//	body, err := retry.DoWithData(
+//		func() ([]byte, error) {
+//			resp, err := http.Get(url)
+//			if err != nil {
+//				return nil, err
+//			}
+//			defer resp.Body.Close()
+//			return ioutil.ReadAll(resp.Body)
+//		},
+//	)
+func DoWithData[T any](retryableFunc RetryableFuncWithData[T], opts ...Option) (T, error) {
+	f := &dataFunc[T]{retryableFunc: retryableFunc}
+	if err := do(context.Background(), f, opts); err != nil {
+		var zero T
+		return zero, err
+	}
+	return f.value, nil
+}
 
+// DoContext is like Do with Context(ctx), retryable function receives
+// context of each attempt (see WithPerAttemptTimeout)
+// Options are applied after ctx, so Context option overrides it.
+func DoContext(ctx context.Context, retryableFunc RetryableContextFunc, opts ...Option) error {
+	return do(ctx, retryableFunc, opts)
+}
+
+// configs are reused by do, so Do of function which succeeds on the first
+// attempt doesn't allocate
+var configs = sync.Pool{New: func() interface{} { return new(config) }}
+
+// newConfig returns default config from the pool, see releaseConfig
+func newConfig(ctx context.Context) *config {
+	c := configs.Get().(*config)
 	//default
-	config := &config{
-		attempts: 10,
-		delay:    100,
-		units:    time.Millisecond,
-		onRetry:  func(n uint, err error) {},
-		retryIf:  func(err error) bool { return true },
+	*c = config{
+		attempts:      10,
+		baseDelay:     100 * time.Millisecond,
+		delay:         100,
+		units:         time.Millisecond,
+		onRetry:       func(n uint, err error) {},
+		onSuccess:     func(attempts uint) {},
+		beforeAttempt: func(n uint) {},
+		afterAttempt:  func(n uint, err error) {},
+		retryIf:       func(err error) bool { return true },
+		delayType:     FixedDelay,
+		aggregate:     func(errs []error) error { return Error(errs) },
+		context:       ctx,
+		globalMetrics: true,
+		clock:         realClock{},
+		sameError:     func(prev, err error) bool { return errors.Is(err, prev) },
 	}
+	return c
+}
+
+// releaseConfig returns c to the pool, it mustn't be used after that
+func releaseConfig(c *config) {
+	*c = config{}
+	configs.Put(c)
+}
+
+func do(ctx context.Context, retryableFunc retryable, opts []Option) error {
+	var n uint
+
+	config := newConfig(ctx)
+	defer releaseConfig(config)
 
 	//apply opts
 	for _, opt := range opts {
 		opt(config)
 	}
 
-	errorLog := make(Error, 0)
+	if config.err != nil {
+		config.giveUp(0, nil, config.err)
+		return config.err
+	}
+
+	if config.randomAttempts {
+		config.attempts = config.minAttempts + uint(config.int63n(int64(config.maxAttempts-config.minAttempts)+1))
+	}
+
+	if err := config.approve(); err != nil {
+		config.giveUp(0, nil, err)
+		return err
+	}
+
+	if config.stackTrace && config.stack == nil {
+		config.stack = callers(2 + config.callerSkip)
+	}
+
+	if config.elapsedInError {
+		config.start = config.clock.Now()
+	}
+
+	errorLog := errorCollector{collect: !config.withoutErrorCollection && !config.lastErrorOnly}
+
+	retrying := false
+	defer func() {
+		if retrying {
+			atomic.AddInt64(&retriesInFlight, -1)
+		}
+	}()
+
+	var calls uint
+	var prevErr error
+	sameErrors := 0
+
+	// count of grace attempts left, negative when not in grace
+	graceLeft := -1
+	var graceCause error
 
 	cond := n < config.attempts
 	if n == 0 {
 		cond = true
 	}
 
+	if config.startupJitter > 0 {
+		if err := config.sleep(config.jitter(config.startupJitter)); err != nil {
+			errorLog.add(err, config.wrap(err))
+			return config.result(errorLog, calls, err)
+		}
+	}
+
+	// why Do gives up, see OnGiveUp
+	var reason error
+
 	for cond {
-		err := retryableFunc()
+		// the breaker may open during the delay (e.g. by other Do calls)
+		if config.breaker != nil && !config.breaker.Allow() {
+			errorLog.add(ErrCircuitOpen, config.wrap(ErrCircuitOpen))
+			reason = ErrCircuitOpen
+			break
+		}
+
+		config.beforeAttempt(n)
+		for _, o := range config.observers {
+			o.BeforeAttempt(n)
+		}
+		err := config.attempt(retryableFunc, n)
+		calls++
+		if graceLeft > 0 {
+			graceLeft--
+		}
+		if err != nil && config.progressed(retryableFunc, calls) {
+			n = 0
+			prevErr = nil
+		}
+
+		if err == Stop {
+			return nil
+		}
+
+		if config.breaker != nil {
+			if err != nil {
+				config.breaker.RecordFailure()
+			} else {
+				config.breaker.RecordSuccess()
+			}
+		}
+
+		if config.observe != nil {
+			config.observe(err)
+		}
 
 		if err != nil {
+			recoverable := IsRecoverable(err)
+			if !recoverable {
+				err = err.(unrecoverableError).error
+			}
+
 			config.onRetry(n, err)
-			errorLog = append(errorLog, err)
+			config.afterAttempt(n, err)
+			for _, o := range config.observers {
+				o.AfterAttempt(n, err)
+			}
+			errorLog.add(err, config.wrap(err))
 
-			if !config.retryIf(err) {
+			// if this is last attempt - don't wait
+			last := n == config.attempts-1 || graceLeft == 0
+
+			if config.maxSameError > 0 {
+				if prevErr != nil && config.sameError(prevErr, err) {
+					sameErrors++
+				} else {
+					sameErrors = 1
+				}
+				prevErr = err
+
+				if sameErrors >= config.maxSameError {
+					reason = ErrSameErrorLimit
+					break
+				}
+			}
+
+			if !recoverable || !config.retryable(err) {
+				reason = ErrNotRetryable
 				break
 			}
 
-			// if this is last attempt - don't wait
-			if n == config.attempts-1 {
+			if last {
+				reason = ErrAttemptsExhausted
 				break
 			}
 
-			time.Sleep((time.Duration)(config.delay) * config.units)
+			// the breaker is consulted before waiting, so open circuit doesn't wait
+			if config.breaker != nil && !config.breaker.Allow() {
+				errorLog.add(ErrCircuitOpen, config.wrap(ErrCircuitOpen))
+				reason = ErrCircuitOpen
+				break
+			}
+
+			// the settle time is waited only when the attempt is retried,
+			// so there is no wait after the last attempt
+			if config.settleTime > 0 {
+				if err := config.sleep(config.settleTime); err != nil {
+					errorLog.add(err, config.wrap(err))
+					reason = err
+					break
+				}
+			}
+
+			if config.globalMetrics && !retrying {
+				atomic.AddInt64(&retriesInFlight, 1)
+				retrying = true
+			}
+
+			delay := config.delayFor(n, err)
+			config.logRetry(n, err, delay)
+			if config.delayChannel != nil {
+				select {
+				case config.delayChannel <- delay:
+				default:
+				}
+			}
+
+			if err := config.sleep(delay); err != nil {
+				if graceLeft >= 0 {
+					// grace budget is exhausted, report the original cause
+					errorLog.add(graceCause, config.wrap(graceCause))
+					reason = graceCause
+					break
+				}
+				if config.graceAttempts <= 0 {
+					errorLog.add(err, config.wrap(err))
+					reason = err
+					break
+				}
+
+				graceCause = err
+				graceLeft = config.graceAttempts
+				graceCtx, cancel := context.WithTimeout(context.WithoutCancel(config.context), config.graceBudget)
+				defer cancel()
+				config.context = graceCtx
+			}
+
+			if config.globalMetrics {
+				atomic.AddInt64(&totalRetries, 1)
+			}
 		} else {
+			config.onSuccess(calls)
 			return nil
 		}
 
 		n++
 	}
 
-	return errorLog
+	return config.result(errorLog, calls, reason)
+}
+
+// errorCollector collects errors of attempts
+type errorCollector struct {
+	errs Error
+	last error
+	// raw is the last error as it is (not wrapped), see LastErrorOnly
+	raw     error
+	collect bool
+}
+
+// add records err and its wrapped form as the last error and appends
+// the wrapped one to errors when collecting
+func (l *errorCollector) add(err, wrapped error) {
+	l.raw = err
+	l.last = wrapped
+	if l.collect {
+		l.errs = append(l.errs, wrapped)
+	}
+}
+
+// delayFor returns delay after attempt n which failed with err
+func (c *config) delayFor(n uint, err error) time.Duration {
+	if n == 0 && c.firstRetryImmediate {
+		return 0
+	}
+
+	var delay time.Duration
+	if c.delaySchedule != nil {
+		if n >= uint(len(c.delaySchedule)) {
+			n = uint(len(c.delaySchedule)) - 1
+		}
+		delay = c.delaySchedule[n]
+	} else {
+		delay = c.delayType(n, err, c)
+	}
+
+	if c.maxDelay > 0 && delay > c.maxDelay {
+		delay = c.maxDelay
+	}
+
+	if c.timeScale > 0 {
+		scaled := float64(delay) * c.timeScale
+		if scaled >= math.MaxInt64 {
+			return math.MaxInt64
+		}
+		delay = time.Duration(scaled)
+	}
+	return delay
+}
+
+// attempt calls retryable function as attempt n with its context,
+// coalesced when WithSingleFlight is set
+func (c *config) attempt(retryableFunc retryable, n uint) error {
+	ctx := c.context
+	if c.perAttemptTimeout != nil {
+		if timeout := c.perAttemptTimeout(n); timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+	}
+
+	holder, hasResult := retryableFunc.(resultHolder)
+	if !hasResult {
+		if c.singleFlight != nil {
+			return c.singleFlight.Do(c.singleFlightKey, func() error {
+				return retryableFunc.call(ctx)
+			})
+		}
+		return retryableFunc.call(ctx)
+	}
+
+	var key string
+	if c.resultCache != nil {
+		key = c.resultCache.key()
+		if value, ok := results.get(key, c.clock.Now()); ok && holder.setResult(value) {
+			return nil
+		}
+	}
+
+	var err error
+	if c.singleFlight != nil {
+		var value interface{}
+		value, err = c.singleFlight.do(c.singleFlightKey, func() (interface{}, error) {
+			err := retryableFunc.call(ctx)
+			return holder.result(), err
+		})
+		// value of other type (shared key with other T) is a miss
+		if err == nil && !holder.setResult(value) {
+			err = retryableFunc.call(ctx)
+		}
+	} else {
+		err = retryableFunc.call(ctx)
+	}
+
+	if err == nil && c.resultCache != nil {
+		results.set(key, holder.result(), c.resultCache.ttl, c.clock.Now())
+	}
+	return err
+}
+
+// wrap prepares error to be collected, it's labeled by WithLabel
+// and timestamped by WithTimestamps
+func (c *config) wrap(err error) error {
+	if c.labelName != "" {
+		err = &labeledError{label: c.labelName, err: err}
+	}
+	if c.timestamps {
+		err = &timedError{at: c.clock.Now(), err: err}
+	}
+	return err
+}
+
+// result makes returned error from errors of all attempts
+// and reports giving up for reason
+func (c *config) result(errorLog errorCollector, attempts uint, reason error) error {
+	var err error
+	if c.lastErrorOnly {
+		err = errorLog.raw
+	} else if errorLog.collect {
+		err = c.aggregate(errorLog.errs)
+	} else {
+		err = c.aggregate(Error{errorLog.last})
+	}
+
+	if c.elapsedInError && err != nil {
+		err = &elapsedError{err: err, attempts: attempts, elapsed: c.clock.Now().Sub(c.start)}
+	}
+
+	if c.stackTrace && err != nil {
+		err = &stackError{err: err, stack: c.stack}
+	}
+
+	if err != nil {
+		errs := []error(errorLog.errs)
+		if !errorLog.collect {
+			errs = []error{errorLog.last}
+		}
+		c.giveUp(attempts, errs, reason)
+	}
+	return err
+}
+
+// elapsedError wraps the final error of Do with its count of attempts and elapsed time
+type elapsedError struct {
+	err      error
+	attempts uint
+	elapsed  time.Duration
+}
+
+func (e *elapsedError) Error() string {
+	return fmt.Sprintf("retry: failed after %d attempts over %s: %s", e.attempts, e.elapsed, e.err)
+}
+
+func (e *elapsedError) Unwrap() error {
+	return e.err
+}
+
+// sleep waits for delay or until the context is done,
+// the context error is mapped by WithContextErrorMapper
+func (c *config) sleep(delay time.Duration) error {
+	if err := c.context.Err(); err != nil || delay <= 0 {
+		return c.contextError(err)
+	}
+
+	after, stop := after(c.timer, delay)
+	defer stop()
+
+	select {
+	case <-after:
+		return nil
+	case <-c.context.Done():
+		return c.contextError(c.context.Err())
+	}
+}
+
+// after returns channel which receives when delay elapses by timer
+// (real timer for nil) and function which releases the real timer
+func after(timer Timer, delay time.Duration) (<-chan time.Time, func()) {
+	if timer != nil {
+		return timer.After(delay), func() {}
+	}
+	t := time.NewTimer(delay)
+	return t.C, func() { t.Stop() }
+}
+
+// contextError maps error of the context by WithContextErrorMapper
+func (c *config) contextError(err error) error {
+	if err == nil || c.contextErrorMapper == nil {
+		return err
+	}
+	return c.contextErrorMapper(err)
 }
 
 // Error type represents list of errors in retry
@@ -119,5 +623,160 @@ type Error []error
 // Error method return string representation of Error
 // It is an implementation of error interface
 func (e Error) Error() string {
-	return e[len(e) - 1].Error()
+	return e[len(e)-1].Error()
+}
+
+// Unwrap returns the last error, so errors.Is and errors.As
+// examine the error which stopped retrying
+func (e Error) Unwrap() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e[len(e)-1]
+}
+
+// Equal reports whether e and other contain errors with the same messages
+// in the same order
+//
+// Errors are compared by messages, not by identity, so aggregates of
+// independently created errors (e.g. in tests) are equal.
+func (e Error) Equal(other Error) bool {
+	if len(e) != len(other) {
+		return false
+	}
+	for i := range e {
+		if errorMessage(e[i]) != errorMessage(other[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Signature returns stable string of messages of contained errors
+// (quoted, in order), equal Errors have the same signature
+//
+// It's suitable as a map key, e.g. `["timeout" "connection refused"]`.
+func (e Error) Signature() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = errorMessage(err)
+	}
+	return fmt.Sprintf("%q", messages)
+}
+
+// TimedError is a collected error with time when it occurred, see WithTimestamps
+type TimedError struct {
+	At  time.Time
+	Err error
+}
+
+// timedError is a collected error with timestamp
+type timedError struct {
+	at  time.Time
+	err error
+}
+
+func (e *timedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *timedError) Unwrap() error {
+	return e.err
+}
+
+// TimedErrors returns errors with time when they occurred
+// (recorded when WithTimestamps is set, errors without it have zero time)
+func (e Error) TimedErrors() []TimedError {
+	timed := make([]TimedError, len(e))
+	for i, err := range e {
+		var t *timedError
+		if errors.As(err, &t) {
+			timed[i] = TimedError{At: t.at, Err: t.err}
+		} else {
+			timed[i] = TimedError{Err: err}
+		}
+	}
+	return timed
+}
+
+// labeledError is a collected error labeled by WithLabel
+type labeledError struct {
+	label string
+	err   error
+}
+
+func (e *labeledError) Error() string {
+	return e.label + ": " + errorMessage(e.err)
+}
+
+func (e *labeledError) Unwrap() error {
+	return e.err
+}
+
+// MarshalJSON encodes errors as an array of objects with attempt index
+// and message, e.g. `[{"attempt":0,"error":"timeout"}]`
+//
+// The attempt index is index of the error in Error, so errors collected
+// besides attempts (e.g. ErrCircuitOpen, error of Context) have own index.
+// Label of WithLabel is encoded as "label" and time of WithTimestamps as "at"
+// (RFC 3339), "error" is the error itself without them:
+// `[{"attempt":0,"error":"timeout","label":"db","at":"2020-01-01T00:00:00Z"}]`
+//
+// Errors implementing json.Marshaler are encoded by themselves, nil error is null.
+func (e Error) MarshalJSON() ([]byte, error) {
+	type attemptError struct {
+		Attempt int         `json:"attempt"`
+		Error   interface{} `json:"error"`
+		Label   string      `json:"label,omitempty"`
+		At      *time.Time  `json:"at,omitempty"`
+	}
+
+	attempts := make([]attemptError, len(e))
+	for i, err := range e {
+		attempts[i].Attempt = i
+		if timed, ok := err.(*timedError); ok {
+			at := timed.at
+			attempts[i].At = &at
+			err = timed.err
+		}
+		if labeled, ok := err.(*labeledError); ok {
+			attempts[i].Label = labeled.label
+			err = labeled.err
+		}
+
+		var marshaler json.Marshaler
+		switch {
+		case err == nil:
+		case errors.As(err, &marshaler):
+			attempts[i].Error = marshaler
+		default:
+			attempts[i].Error = err.Error()
+		}
+	}
+	return json.Marshal(attempts)
+}
+
+// errorMessage returns message of err, nil has "<nil>"
+func errorMessage(err error) string {
+	if err == nil {
+		return "<nil>"
+	}
+	return err.Error()
+}
+
+type unrecoverableError struct {
+	error
+}
+
+// Unrecoverable wraps an error in a type which makes Do stop retrying
+// and fail with the wrapped error immediately
+// (use Stop for stopping without failure)
+func Unrecoverable(err error) error {
+	return unrecoverableError{err}
+}
+
+// IsRecoverable checks if error is not wrapped by Unrecoverable
+func IsRecoverable(err error) bool {
+	_, isUnrecoverable := err.(unrecoverableError)
+	return !isUnrecoverable
 }
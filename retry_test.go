@@ -1,8 +1,13 @@
 package retry
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -76,3 +81,1182 @@ func TestAttempts(t *testing.T) {
 	assert.Error(t, err)
 	assert.Equal(t, uint(3), retryCount, "right count of retry")
 }
+
+func TestFixedDelay(t *testing.T) {
+	start := time.Now()
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Delay(10),
+		DelayType(FixedDelay),
+	)
+	dur := time.Since(start)
+	assert.Error(t, err)
+	assert.True(t, dur > 20*time.Millisecond, "2 fixed delays of 10ms are longer then 20ms")
+}
+
+func TestConstantJitterDelay(t *testing.T) {
+	base := 10 * time.Millisecond
+	jitter := 5 * time.Millisecond
+	delayType := ConstantJitterDelay(base, jitter)
+
+	for n := uint(0); n < 100; n++ {
+		delay := delayType(n, nil, nil)
+		assert.True(t, delay >= base, "delay is not shorter then base")
+		assert.True(t, delay <= base+jitter, "delay is not longer then base + jitter")
+	}
+
+	assert.Equal(t, base, ConstantJitterDelay(base, 0)(5, nil, nil), "no jitter")
+
+	delay := ConstantJitterDelay(base, math.MaxInt64)(0, nil, nil)
+	assert.True(t, delay >= base, "maximal jitter doesn't panic")
+	delay = ConstantJitterDelay(math.MaxInt64, jitter)(0, nil, nil)
+	assert.Equal(t, time.Duration(math.MaxInt64), delay, "base + jitter is saturated")
+}
+
+func TestOnSuccess(t *testing.T) {
+	var attempts uint
+	var calls uint
+	err := Do(
+		func() error {
+			calls++
+			if calls < 3 {
+				return errors.New("test")
+			}
+			return nil
+		},
+		OnSuccess(func(n uint) { attempts = n }),
+		Units(time.Nanosecond),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), attempts, "attempts are count of calls")
+
+	err = Do(
+		func() error { return nil },
+		OnSuccess(func(n uint) { attempts = n }),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), attempts, "first try succeeded")
+}
+
+func TestWithErrorAggregator(t *testing.T) {
+	var collected []error
+	countErr := errors.New("count")
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithErrorAggregator(func(errs []error) error {
+			collected = errs
+			return countErr
+		}),
+	)
+	assert.Equal(t, countErr, err, "aggregated error is returned")
+	assert.Len(t, collected, 3, "errors of all attempts")
+
+	err = Do(
+		func() error { return errors.New("test") },
+		Attempts(2),
+		Units(time.Nanosecond),
+	)
+	assert.Len(t, err, 2, "default is Error")
+}
+
+func TestAfterAttempt(t *testing.T) {
+	var events []string
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		OnRetry(func(n uint, err error) { events = append(events, "retry") }),
+		AfterAttempt(func(n uint, err error) { events = append(events, "after") }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"retry", "after", "retry", "after", "retry", "after"}, events, "called after OnRetry on each attempt including the last")
+
+	var afterCount uint
+	err = Do(
+		func() error { return errors.New("special") },
+		RetryIf(func(err error) bool { return false }),
+		AfterAttempt(func(n uint, err error) { afterCount++ }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, uint(1), afterCount, "called on unretryable error")
+}
+
+func TestStop(t *testing.T) {
+	var calls uint
+	err := Do(
+		func() error {
+			calls++
+			if calls == 2 {
+				return Stop
+			}
+			return errors.New("test")
+		},
+		Units(time.Nanosecond),
+	)
+	assert.NoError(t, err, "stop is not a failure")
+	assert.Equal(t, uint(2), calls, "no attempt after stop")
+}
+
+func TestUnrecoverable(t *testing.T) {
+	var calls uint
+	unrecoverableErr := errors.New("unrecoverable")
+	err := Do(
+		func() error {
+			calls++
+			if calls == 2 {
+				return Unrecoverable(unrecoverableErr)
+			}
+			return errors.New("test")
+		},
+		Units(time.Nanosecond),
+	)
+	assert.Error(t, err, "unrecoverable is a failure")
+	assert.Equal(t, uint(2), calls, "no attempt after unrecoverable error")
+	assert.Equal(t, unrecoverableErr, err.(Error)[1], "unwrapped error is collected")
+	assert.False(t, IsRecoverable(Unrecoverable(unrecoverableErr)))
+	assert.True(t, IsRecoverable(unrecoverableErr))
+}
+
+func TestBeforeAttempt(t *testing.T) {
+	var attempts []uint
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		BeforeAttempt(func(n uint) { attempts = append(attempts, n) }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []uint{0, 1, 2}, attempts, "called before each attempt with its number")
+}
+
+type eventObserver struct {
+	name   string
+	events *[]string
+}
+
+func (o eventObserver) BeforeAttempt(n uint) {
+	*o.events = append(*o.events, fmt.Sprintf("%s before %d", o.name, n))
+}
+
+func (o eventObserver) AfterAttempt(n uint, err error) {
+	*o.events = append(*o.events, fmt.Sprintf("%s after %d: %v", o.name, n, err))
+}
+
+func TestWithObserver(t *testing.T) {
+	var events []string
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithObserver(eventObserver{"a", &events}),
+		BeforeAttempt(func(n uint) { events = append(events, fmt.Sprintf("callback before %d", n)) }),
+		AfterAttempt(func(n uint, err error) { events = append(events, fmt.Sprintf("callback after %d", n)) }),
+		WithObserver(eventObserver{"b", &events}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"callback before 0", "a before 0", "b before 0",
+		"callback after 0", "a after 0: test", "b after 0: test",
+		"callback before 1", "a before 1", "b before 1",
+		"callback after 1", "a after 1: test", "b after 1: test",
+	}, events, "observers don't replace each other nor callbacks")
+}
+
+func TestContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls uint
+	err := Do(
+		func() error {
+			calls++
+			cancel()
+			return errors.New("test")
+		},
+		Context(ctx),
+		Delay(time.Hour),
+		Units(1),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, uint(1), calls, "no attempt after cancel")
+	assert.Equal(t, context.Canceled, err.(Error)[1], "context error is the last one")
+}
+
+func TestWithStartupJitter(t *testing.T) {
+	start := time.Now()
+	err := Do(
+		func() error { return nil },
+		WithStartupJitter(10*time.Millisecond),
+	)
+	assert.NoError(t, err)
+	assert.True(t, time.Since(start) < time.Second, "startup jitter is bounded")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls uint
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		Context(ctx),
+		WithStartupJitter(time.Hour),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, uint(0), calls, "no attempt after cancel during startup jitter")
+	assert.Equal(t, context.Canceled, err.(Error)[0])
+
+	err = Do(
+		func() error { return nil },
+		Context(ctx),
+		WithStartupJitter(math.MaxInt64),
+	)
+	assert.Equal(t, context.Canceled, err.(Error)[0], "maximal startup jitter doesn't panic")
+}
+
+func TestWithDelayChannel(t *testing.T) {
+	delays := make(chan time.Duration, 10)
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(4),
+		Delay(1),
+		Units(time.Millisecond),
+		DelayType(LinearDelay),
+		WithDelayChannel(delays),
+	)
+	assert.Error(t, err)
+	close(delays)
+
+	var received []time.Duration
+	for delay := range delays {
+		received = append(received, delay)
+	}
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond}, received, "delay before each retry")
+
+	unbuffered := make(chan time.Duration)
+	err = Do(
+		func() error { return errors.New("test") },
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithDelayChannel(unbuffered),
+	)
+	assert.Error(t, err, "no receiver doesn't block")
+}
+
+func TestLastErrorOnly(t *testing.T) {
+	var calls int
+	errs := []error{errors.New("first"), errors.New("second"), errors.New("third")}
+	err := Do(
+		func() error {
+			calls++
+			return errs[calls-1]
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		LastErrorOnly(true),
+	)
+	assert.True(t, err == errs[2], "the last error instance is returned")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return errs[calls-1]
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		LastErrorOnly(true),
+		WithLabel("db"),
+		WithTimestamps(true),
+	)
+	assert.True(t, err == errs[2], "the instance isn't labeled nor timestamped")
+}
+
+func TestDeterministicJitter(t *testing.T) {
+	run := func(seed int64) []time.Duration {
+		delays := make(chan time.Duration, 10)
+		err := Do(
+			func() error { return errors.New("test") },
+			Attempts(6),
+			Delay(100),
+			Units(time.Microsecond),
+			DelayType(DeterministicJitter(seed)),
+			WithDelayChannel(delays),
+		)
+		assert.Error(t, err)
+		close(delays)
+
+		var received []time.Duration
+		for delay := range delays {
+			assert.True(t, delay >= 0 && delay <= 100*time.Microsecond, "jitter is in [0, delay]")
+			received = append(received, delay)
+		}
+		return received
+	}
+
+	first := run(42)
+	assert.Len(t, first, 5)
+	assert.Equal(t, first, run(42), "the same seed produces the same delays")
+	assert.NotEqual(t, first, run(43), "another seed produces another delays")
+}
+
+func TestWithLabel(t *testing.T) {
+	originalErr := errors.New("connection refused")
+	var callbackErr error
+	err := Do(
+		func() error { return originalErr },
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithLabel("fetch config"),
+		OnRetry(func(n uint, err error) { callbackErr = err }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "fetch config: connection refused", err.Error(), "labeled error")
+	for _, e := range err.(Error) {
+		assert.True(t, errors.Is(e, originalErr), "labeled error unwraps to original")
+	}
+	assert.True(t, callbackErr == originalErr, "callback receives original error")
+}
+
+func TestWithoutErrorCollection(t *testing.T) {
+	var calls int
+	var aggregated []error
+	err := Do(
+		func() error {
+			calls++
+			return errors.New("test " + string(rune('0'+calls)))
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithoutErrorCollection(true),
+		WithErrorAggregator(func(errs []error) error {
+			aggregated = errs
+			return Error(errs)
+		}),
+	)
+	assert.Error(t, err)
+	assert.Len(t, aggregated, 1, "only the last error is kept")
+	assert.Equal(t, "test 3", err.Error())
+}
+
+var errBenchmark = errors.New("benchmark")
+
+func benchmarkDoFailing(b *testing.B, opts ...Option) {
+	opts = append(opts, Attempts(5), Delay(0))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Do(func() error { return errBenchmark }, opts...)
+	}
+}
+
+func BenchmarkDoSuccess(b *testing.B) {
+	b.ReportAllocs()
+	f := func() error { return nil }
+	for i := 0; i < b.N; i++ {
+		_ = Do(f, Attempts(3))
+	}
+}
+
+func TestDoSuccessDoesNotAllocate(t *testing.T) {
+	f := func() error { return nil }
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = Do(f, Attempts(3))
+	})
+	assert.Equal(t, float64(0), allocs)
+}
+
+func BenchmarkDoErrorCollection(b *testing.B) {
+	benchmarkDoFailing(b)
+}
+
+func BenchmarkDoWithoutErrorCollection(b *testing.B) {
+	benchmarkDoFailing(b, WithoutErrorCollection(true))
+}
+
+func BenchmarkDoLastErrorOnly(b *testing.B) {
+	benchmarkDoFailing(b, LastErrorOnly(true))
+}
+
+func TestMaxConsecutiveSameError(t *testing.T) {
+	sameErr := errors.New("same")
+	var calls int
+	err := Do(
+		func() error {
+			calls++
+			if calls == 2 {
+				return errors.New("other")
+			}
+			return sameErr
+		},
+		Units(time.Nanosecond),
+		MaxConsecutiveSameError(3),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 5, calls, "aborted after 3 same errors in a row")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return sameErr
+		},
+		Attempts(2),
+		Units(time.Nanosecond),
+		MaxConsecutiveSameError(3),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls, "attempts limit triggers first")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return errors.New("same message")
+		},
+		Units(time.Nanosecond),
+		MaxConsecutiveSameError(2),
+		SameError(func(prev, err error) bool { return prev.Error() == err.Error() }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 2, calls, "custom comparator")
+}
+
+func TestWithDelaySchedule(t *testing.T) {
+	delays := make(chan time.Duration, 10)
+	schedule := []time.Duration{3 * time.Microsecond, time.Microsecond, 2 * time.Microsecond}
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(6),
+		WithDelaySchedule(schedule),
+		DelayType(BackOffDelay),
+		WithDelayChannel(delays),
+	)
+	assert.Error(t, err)
+	close(delays)
+
+	var received []time.Duration
+	for delay := range delays {
+		received = append(received, delay)
+	}
+	assert.Equal(t, append(schedule, 2*time.Microsecond, 2*time.Microsecond), received, "schedule overrides delay type, the last delay repeats")
+
+	var calls int
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		WithDelaySchedule(nil),
+	)
+	assert.EqualError(t, err, "retry: empty delay schedule")
+	assert.Equal(t, 0, calls, "no attempt with invalid config")
+
+	policy := NewPolicy(WithDelaySchedule(nil))
+	err = Do(
+		func() error {
+			calls++
+			return nil
+		},
+		WithPolicy(policy),
+		WithDelaySchedule(schedule),
+	)
+	assert.NoError(t, err, "later valid schedule overrides the empty one")
+	assert.Equal(t, 1, calls)
+
+	err = Do(
+		func() error { return nil },
+		WithDelaySchedule(nil),
+		WithPerAttemptTimeout(0),
+		WithDelaySchedule(schedule),
+	)
+	assert.EqualError(t, err, "retry: non-positive per-attempt timeout", "other config errors are kept")
+
+	mutable := []time.Duration{time.Nanosecond}
+	option := WithDelaySchedule(mutable)
+	mutable[0] = time.Hour
+	delays = make(chan time.Duration, 1)
+	Do(
+		func() error { return errors.New("test") },
+		Attempts(2),
+		option,
+		WithDelayChannel(delays),
+	)
+	assert.Equal(t, time.Nanosecond, <-delays, "schedule is copied")
+}
+
+func TestConfigErrorOverridden(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"per-attempt timeout":      {WithPerAttemptTimeout(0), WithPerAttemptTimeout(time.Second)},
+		"per-attempt timeout func": {WithPerAttemptTimeout(0), WithPerAttemptTimeoutFunc(func(uint) time.Duration { return 0 })},
+		"random attempts":          {RandomAttempts(1, math.MaxUint), RandomAttempts(1, 2)},
+		"time scale":               {WithTimeScale(0), WithTimeScale(1)},
+		"backoff":                  {Backoff(BackoffType(42)), Backoff(BackoffFixed)},
+		"policy":                   {WithPolicy(NewPolicy(WithTimeScale(0))), WithTimeScale(1)},
+	} {
+		err := DoContext(context.Background(), func(context.Context) error { return nil }, opts...)
+		assert.NoError(t, err, "%s: later valid value overrides invalid one", name)
+	}
+
+	err := Do(func() error { return nil }, WithTimeScale(0), Backoff(BackoffFixed))
+	assert.EqualError(t, err, "retry: non-positive time scale", "other option keeps the error")
+}
+
+func TestDoContextPerAttemptTimeout(t *testing.T) {
+	var timeouts []time.Duration
+	var hasDeadline []bool
+	err := DoContext(context.Background(),
+		func(ctx context.Context) error {
+			deadline, ok := ctx.Deadline()
+			hasDeadline = append(hasDeadline, ok)
+			if ok {
+				// round to the expected seconds
+				timeouts = append(timeouts, time.Until(deadline).Round(time.Second))
+			}
+			return errors.New("test")
+		},
+		Attempts(4),
+		Units(time.Nanosecond),
+		WithPerAttemptTimeoutFunc(func(n uint) time.Duration {
+			return time.Duration(n) * time.Minute
+		}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []bool{false, true, true, true}, hasDeadline, "non-positive timeout means no deadline")
+	assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute}, timeouts, "deadline by attempt")
+
+	err = DoContext(context.Background(),
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithPerAttemptTimeout(time.Millisecond),
+	)
+	assert.Error(t, err)
+	assert.Len(t, err, 2, "the timed out attempt is retried")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	err = DoContext(context.Background(),
+		func(ctx context.Context) error { return nil },
+		WithPerAttemptTimeout(0),
+	)
+	assert.EqualError(t, err, "retry: non-positive per-attempt timeout")
+}
+
+func TestRetryOnPerAttemptTimeout(t *testing.T) {
+	timedOut := func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("query: %w", ctx.Err())
+	}
+
+	calls := 0
+	err := DoContext(context.Background(),
+		func(ctx context.Context) error {
+			calls++
+			return timedOut(ctx)
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithPerAttemptTimeout(time.Millisecond),
+		RetryIf(func(err error) bool { return !errors.Is(err, context.DeadlineExceeded) }),
+		RetryOnPerAttemptTimeout(true),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 3, calls, "per-attempt timeout is retried despite RetryIf")
+
+	calls = 0
+	err = DoContext(context.Background(),
+		func(ctx context.Context) error {
+			calls++
+			return timedOut(ctx)
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithPerAttemptTimeout(time.Millisecond),
+		RetryOnPerAttemptTimeout(false),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, calls, "per-attempt timeout is not retried")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	calls = 0
+	err = DoContext(ctx,
+		func(ctx context.Context) error {
+			calls++
+			return timedOut(ctx)
+		},
+		Attempts(3),
+		Delay(time.Hour),
+		WithPerAttemptTimeout(time.Minute),
+		RetryOnPerAttemptTimeout(true),
+	)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, 1, calls, "deadline of the whole Do stops")
+
+	calls = 0
+	err = Do(
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		RetryOnPerAttemptTimeout(false),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls, "other errors are left to RetryIf")
+}
+
+func TestWhen(t *testing.T) {
+	run := func(isProd bool) int {
+		calls := 0
+		Do(
+			func() error {
+				calls++
+				return errors.New("test")
+			},
+			Units(time.Nanosecond),
+			When(isProd, Attempts(5)),
+			When(!isProd, Attempts(2)),
+		)
+		return calls
+	}
+	assert.Equal(t, 5, run(true))
+	assert.Equal(t, 2, run(false))
+
+	calls := 0
+	Do(
+		func() error {
+			calls++
+			return errors.New("test")
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		When(false, Attempts(1)),
+		When(true),
+	)
+	assert.Equal(t, 3, calls, "false condition and no options are no-ops")
+}
+
+func TestWithTimeScale(t *testing.T) {
+	delays := func(opts ...Option) []time.Duration {
+		ch := make(chan time.Duration, 10)
+		err := Do(
+			func() error { return errors.New("test") },
+			append([]Option{
+				Attempts(4),
+				Delay(time.Second),
+				Units(1),
+				DelayType(BackOffDelay),
+				MaxDelay(3 * time.Second),
+				WithDelayChannel(ch),
+				WithTimer(recordingTimer{events: &[]string{}}),
+			}, opts...)...,
+		)
+		assert.Error(t, err)
+		return received(ch)
+	}
+
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, delays())
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond}, delays(WithTimeScale(0.1)), "scaled after MaxDelay")
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, delays(WithTimeScale(1)))
+	assert.Equal(t, []time.Duration{time.Duration(math.MaxInt64)}, delays(Attempts(2), MaxDelay(0), WithTimeScale(math.MaxFloat64)), "saturates")
+
+	for _, factor := range []float64{0, -1, math.NaN()} {
+		err := Do(func() error { return nil }, WithTimeScale(factor))
+		assert.EqualError(t, err, "retry: non-positive time scale")
+	}
+}
+
+// cursor fails on each odd page fetch
+type cursor struct {
+	page    int
+	fetches int
+}
+
+func (c *cursor) Attempt() error {
+	c.fetches++
+	if c.fetches%2 == 1 {
+		return errors.New("test")
+	}
+	c.page++
+	if c.page < 3 {
+		return errors.New("more pages")
+	}
+	return nil
+}
+
+func TestDoRetryable(t *testing.T) {
+	c := &cursor{}
+	err := DoRetryable(c, Units(time.Nanosecond))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, c.page, "state is kept across attempts")
+	assert.Equal(t, 6, c.fetches)
+
+	c = &cursor{}
+	err = DoRetryable(c, Attempts(2), Units(time.Nanosecond))
+	assert.Error(t, err)
+	assert.Len(t, err, 2)
+	assert.Equal(t, 1, c.page)
+}
+
+func TestDelayDuration(t *testing.T) {
+	delays := func(opts ...Option) []time.Duration {
+		var plan []PlannedDelay
+		err := Do(
+			func() error { return nil },
+			append([]Option{
+				Attempts(4),
+				DelayType(BackOffDelay),
+				WithPlanApproval(func(p []PlannedDelay) bool {
+					plan = p
+					return true
+				}),
+			}, opts...)...,
+		)
+		assert.NoError(t, err)
+		var r []time.Duration
+		for _, p := range plan {
+			r = append(r, p.Delay)
+		}
+		return r
+	}
+
+	expected := []time.Duration{100 * time.Second, 200 * time.Second, 400 * time.Second}
+	assert.Equal(t, expected, delays(DelayDuration(100*time.Second)))
+	assert.Equal(t, expected, delays(Delay(100), Units(time.Second)))
+	assert.Equal(t, expected, delays(Units(time.Second), Delay(100)), "order of legacy options doesn't matter")
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}, delays(), "default")
+	assert.Equal(t, []time.Duration{100 * time.Nanosecond, 200 * time.Nanosecond, 400 * time.Nanosecond}, delays(Units(time.Nanosecond)))
+
+	ms := 100 * time.Millisecond
+	assert.Equal(t, []time.Duration{ms, 2 * ms, 4 * ms}, delays(DelayDuration(ms), Units(time.Millisecond)), "later Units doesn't apply to DelayDuration")
+	assert.Equal(t, []time.Duration{ms, 2 * ms, 4 * ms}, delays(Units(time.Second), DelayDuration(ms)))
+	assert.Equal(t, []time.Duration{ms, 2 * ms, 4 * ms}, delays(DelayDuration(time.Hour), Delay(100)), "later Delay is in the default units")
+	assert.Equal(t, []time.Duration{100 * time.Second, 200 * time.Second, 400 * time.Second}, delays(DelayDuration(time.Hour), Delay(100), Units(time.Second)))
+
+	max := time.Duration(math.MaxInt64)
+	assert.Equal(t, []time.Duration{max, max, max}, delays(Delay(time.Hour), Units(time.Hour)), "overflow is saturated")
+	assert.Equal(t, []time.Duration{0, 0, 0}, delays(Units(-time.Second)), "negative units")
+}
+
+func TestMaxDelay(t *testing.T) {
+	delays := make(chan time.Duration, 10)
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(5),
+		Delay(time.Microsecond),
+		Units(1),
+		DelayType(BackOffDelay),
+		MaxDelay(3*time.Microsecond),
+		WithDelayChannel(delays),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{time.Microsecond, 2 * time.Microsecond, 3 * time.Microsecond, 3 * time.Microsecond}, received(delays))
+}
+
+func TestJitterWhen(t *testing.T) {
+	contention := errors.New("contention")
+	c := &config{baseDelay: 10 * time.Millisecond}
+	jitter := 5 * time.Millisecond
+	delayType := JitterWhen(func(err error) bool { return errors.Is(err, contention) }, jitter)
+
+	jittered := false
+	for n := uint(0); n < 100; n++ {
+		delay := delayType(n, contention, c)
+		assert.True(t, delay >= 10*time.Millisecond && delay <= 10*time.Millisecond+jitter, "jitter on matching error")
+		jittered = jittered || delay != 10*time.Millisecond
+
+		assert.Equal(t, 10*time.Millisecond, delayType(n, errors.New("timeout"), c), "plain delay on other error")
+	}
+	assert.True(t, jittered, "matching error is jittered")
+
+	delay := JitterWhen(func(error) bool { return true }, math.MaxInt64)(0, contention, c)
+	assert.True(t, delay >= 10*time.Millisecond, "maximal jitter doesn't panic")
+	delay = JitterWhen(func(error) bool { return true }, jitter)(0, contention, &config{baseDelay: math.MaxInt64})
+	assert.Equal(t, time.Duration(math.MaxInt64), delay, "delay + jitter is saturated")
+}
+
+func TestErrorEqual(t *testing.T) {
+	a := Error{errors.New("timeout"), errors.New("connection refused")}
+	b := Error{errors.New("timeout"), errors.New("connection refused")}
+
+	assert.True(t, a.Equal(b), "equal by messages")
+	assert.False(t, a.Equal(b[:1]), "different length")
+	assert.False(t, a.Equal(Error{b[1], b[0]}), "different order")
+	assert.True(t, Error{nil}.Equal(Error{nil}))
+
+	assert.Equal(t, `["timeout" "connection refused"]`, a.Signature())
+	assert.Equal(t, a.Signature(), b.Signature(), "equal errors have the same signature")
+	assert.NotEqual(t, a.Signature(), Error{errors.New(`timeout" "connection refused`)}.Signature(), "messages are quoted")
+
+	set := map[string]bool{a.Signature(): true}
+	assert.True(t, set[b.Signature()])
+}
+
+func TestWithGraceAttempts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls int
+	var attemptCtxErrs []error
+	err := DoContext(ctx,
+		func(ctx context.Context) error {
+			calls++
+			attemptCtxErrs = append(attemptCtxErrs, ctx.Err())
+			if calls == 1 {
+				cancel()
+			}
+			return errors.New("test")
+		},
+		Units(time.Nanosecond),
+		WithGraceAttempts(2, time.Minute),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls, "grace attempts after cancel")
+	assert.Equal(t, []error{nil, nil, nil}, attemptCtxErrs, "grace attempts aren't cancelled")
+	assert.Equal(t, "test", err.Error(), "the last error of grace attempt")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	err = DoContext(ctx,
+		func(ctx context.Context) error {
+			calls++
+			cancel()
+			return errors.New("test")
+		},
+		Delay(10*time.Millisecond),
+		Units(1),
+		WithGraceAttempts(5, 25*time.Millisecond),
+	)
+	assert.Error(t, err)
+	assert.True(t, calls >= 2 && calls < 6, "grace attempts are limited by budget")
+	assert.True(t, errors.Is(err, context.Canceled), "the original cause is the last error")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	calls = 0
+	err = DoContext(ctx,
+		func(ctx context.Context) error {
+			calls++
+			cancel()
+			return errors.New("test")
+		},
+		Units(time.Nanosecond),
+	)
+	assert.Equal(t, 1, calls, "no grace attempts by default")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestWithContextErrorMapper(t *testing.T) {
+	errShuttingDown := errors.New("shutting down")
+	ctx, cancel := context.WithCancel(context.Background())
+	err := Do(
+		func() error {
+			cancel()
+			return errors.New("test")
+		},
+		Context(ctx),
+		WithContextErrorMapper(func(err error) error {
+			return fmt.Errorf("%w: %w", errShuttingDown, err)
+		}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "shutting down: context canceled", err.Error())
+	assert.True(t, errors.Is(err, errShuttingDown), "mapped error")
+	assert.True(t, errors.Is(err, context.Canceled), "mapped error unwraps to the context error")
+}
+
+func TestRandomAttempts(t *testing.T) {
+	run := func(seed int64) []int {
+		r := rand.New(rand.NewSource(seed))
+		var counts []int
+		for i := 0; i < 20; i++ {
+			calls := 0
+			err := Do(
+				func() error {
+					calls++
+					return errors.New("test")
+				},
+				Units(time.Nanosecond),
+				WithRand(r),
+				RandomAttempts(2, 5),
+			)
+			assert.Error(t, err)
+			assert.True(t, calls >= 2 && calls <= 5, "attempts are in [min, max]")
+			counts = append(counts, calls)
+		}
+		return counts
+	}
+
+	counts := run(1)
+	assert.Equal(t, counts, run(1), "the same seed produces the same attempts")
+
+	seen := map[int]bool{}
+	for _, calls := range counts {
+		seen[calls] = true
+	}
+	assert.True(t, len(seen) > 1, "attempts are random")
+
+	err := Do(func() error { return nil }, RandomAttempts(3, 2))
+	assert.EqualError(t, err, "retry: random attempts min is greater than max")
+
+	err = Do(func() error { return nil }, RandomAttempts(0, 2))
+	assert.EqualError(t, err, "retry: random attempts min is zero", "zero would be unlimited attempts")
+
+	err = Do(func() error { return nil }, RandomAttempts(1, math.MaxUint))
+	assert.EqualError(t, err, "retry: random attempts range is too wide")
+
+	err = Do(func() error { return nil }, RandomAttempts(1, math.MaxInt64))
+	assert.NoError(t, err, "the widest range")
+}
+
+func TestWithRand(t *testing.T) {
+	delayType := ConstantJitterDelay(0, time.Hour)
+	a := &config{rand: rand.New(rand.NewSource(7))}
+	b := &config{rand: rand.New(rand.NewSource(7))}
+	for n := uint(0); n < 10; n++ {
+		assert.Equal(t, delayType(n, nil, a), delayType(n, nil, b), "seeded jitter is reproducible")
+	}
+}
+
+func TestWithSettleTime(t *testing.T) {
+	var events []string
+	err := Do(
+		func() error {
+			events = append(events, "attempt")
+			return errors.New("test")
+		},
+		Attempts(3),
+		Units(time.Nanosecond),
+		WithSettleTime(settleSentinel),
+		WithTimer(recordingTimer{events: &events}),
+		RetryIf(func(err error) bool {
+			events = append(events, "retryIf")
+			return true
+		}),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []string{
+		"attempt", "retryIf", "settle", "sleep",
+		"attempt", "retryIf", "settle", "sleep",
+		"attempt", "retryIf",
+	}, events, "settle after evaluation, none after the last attempt")
+
+	events = nil
+	err = Do(
+		func() error {
+			events = append(events, "attempt")
+			return errors.New("test")
+		},
+		Attempts(3),
+		WithSettleTime(settleSentinel),
+		WithTimer(recordingTimer{events: &events}),
+		RetryIf(func(err error) bool { return false }),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []string{"attempt"}, events, "no settle when RetryIf stops")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = Do(
+		func() error {
+			cancel()
+			return errors.New("test")
+		},
+		Context(ctx),
+		WithSettleTime(time.Hour),
+	)
+	assert.True(t, errors.Is(err, context.Canceled), "settle respects context")
+}
+
+type jsonError struct {
+	Code int `json:"code"`
+}
+
+func (e jsonError) Error() string { return "json error" }
+
+func (e jsonError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Code int `json:"code"`
+	}{e.Code})
+}
+
+func TestErrorMarshalJSON(t *testing.T) {
+	e := Error{errors.New("timeout"), jsonError{Code: 503}, nil}
+	data, err := json.Marshal(e)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"attempt":0,"error":"timeout"},{"attempt":1,"error":{"code":503}},{"attempt":2,"error":null}]`, string(data))
+	assert.Equal(t, "json error", e[1].Error(), "text representation is unchanged")
+
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	errs := []error{fmt.Errorf("call: %w", jsonError{Code: 503}), errors.New("timeout")}
+	err = Do(
+		func() error {
+			err := errs[0]
+			errs = errs[1:]
+			return err
+		},
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithLabel("api"),
+		WithTimestamps(true),
+		WithClock(&stepClock{now: at}),
+	)
+	data, _ = json.Marshal(err)
+	assert.Equal(t, `[{"attempt":0,"error":{"code":503},"label":"api","at":"2020-01-01T00:00:00Z"},`+
+		`{"attempt":1,"error":"timeout","label":"api","at":"2020-01-01T00:00:00Z"}]`, string(data), "label and time are kept")
+	assert.EqualError(t, err.(Error)[0], "api: call: json error")
+}
+
+func TestFirstRetryImmediate(t *testing.T) {
+	delays := make(chan time.Duration, 10)
+	err := Do(
+		func() error { return errors.New("test") },
+		Attempts(4),
+		Delay(time.Microsecond),
+		Units(1),
+		DelayType(BackOffDelay),
+		FirstRetryImmediate(true),
+		WithDelayChannel(delays),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{0, 2 * time.Microsecond, 4 * time.Microsecond}, received(delays), "immediate first retry, backoff after that")
+}
+
+func TestDoWithData(t *testing.T) {
+	var calls int
+	value, err := DoWithData(
+		func() (int, error) {
+			calls++
+			if calls < 3 {
+				return calls, errors.New("test")
+			}
+			return calls, nil
+		},
+		Units(time.Nanosecond),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, value)
+
+	value, err = DoWithData(
+		func() (int, error) { return 42, errors.New("test") },
+		Attempts(2),
+		Units(time.Nanosecond),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, 0, value, "zero value on failure")
+}
+
+// settleSentinel is a settle time distinct from all delays of the fuzz test
+const settleSentinel = time.Hour
+
+// recordingTimer records delays as events and elapses immediately
+type recordingTimer struct {
+	events *[]string
+}
+
+func (t recordingTimer) After(delay time.Duration) <-chan time.Time {
+	if delay == settleSentinel {
+		*t.events = append(*t.events, "settle")
+	} else {
+		*t.events = append(*t.events, "sleep")
+	}
+	ch := make(chan time.Time, 1)
+	ch <- time.Time{}
+	return ch
+}
+
+func FuzzNoSleepAfterLastAttempt(f *testing.F) {
+	f.Add(uint8(3), uint8(10), uint16(0), uint8(0))
+	f.Add(uint8(5), uint8(2), uint16(0xffff), uint8(1))
+	f.Add(uint8(4), uint8(10), uint16(1<<4|1<<5), uint8(2))
+	f.Add(uint8(6), uint8(10), uint16(1<<6|1<<4), uint8(2))
+	f.Add(uint8(6), uint8(10), uint16(1<<7), uint8(2))
+	f.Add(uint8(6), uint8(10), uint16(1<<8|1<<9), uint8(3))
+	f.Add(uint8(0), uint8(10), uint16(1<<8), uint8(4))
+
+	f.Fuzz(func(t *testing.T, attempts, failures uint8, flags uint16, stopAt uint8) {
+		has := func(bit uint) bool { return flags&(1<<bit) != 0 }
+		if attempts == 0 && !has(5) && !has(6) && !has(7) && !has(8) && !has(11) {
+			// unlimited attempts end only by success
+			failures %= 50
+		}
+
+		var events []string
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		breaker := &countingBreaker{maxFailures: int(stopAt) + 1}
+		opts := []Option{
+			Attempts(uint(attempts)),
+			Delay(1),
+			Units(1),
+			WithTimer(recordingTimer{events: &events}),
+		}
+		if has(0) {
+			opts = append(opts, MaxDelay(5))
+		}
+		if has(1) {
+			opts = append(opts, DelayType(ConstantJitterDelay(1, 10)))
+		}
+		if has(2) {
+			opts = append(opts, WithDelaySchedule([]time.Duration{1, 2, 3}))
+		}
+		if has(3) {
+			opts = append(opts, FirstRetryImmediate(true))
+		}
+		if has(4) {
+			opts = append(opts, WithSettleTime(settleSentinel))
+		}
+		if has(5) {
+			var count uint8
+			opts = append(opts, RetryIf(func(err error) bool {
+				count++
+				return count <= stopAt
+			}))
+		}
+		if has(6) {
+			opts = append(opts, MaxConsecutiveSameError(int(stopAt)))
+		}
+		if has(7) {
+			opts = append(opts, WithBreaker(breaker))
+		}
+		if has(9) {
+			opts = append(opts, WithGraceAttempts(int(stopAt%3), time.Minute))
+		}
+		if has(10) {
+			opts = append(opts, DelayType(BackOffDelay), MaxDelay(1000))
+		}
+		if has(12) {
+			opts = append(opts, WithStartupJitter(1))
+		}
+		if has(13) {
+			opts = append(opts, WithPerAttemptTimeout(time.Minute))
+		}
+
+		sameErr := errors.New("test")
+		var calls uint8
+		_ = DoContext(ctx,
+			func(ctx context.Context) error {
+				calls++
+				events = append(events, "attempt")
+				if has(8) && calls == stopAt {
+					cancel()
+				}
+				if has(11) && calls == stopAt {
+					return Unrecoverable(sameErr)
+				}
+				if calls > failures {
+					return nil
+				}
+				return sameErr
+			},
+			opts...,
+		)
+
+		// settle time is a sleep as well
+		if len(events) > 0 && events[len(events)-1] != "attempt" {
+			t.Fatalf("sleep after the last attempt: %v", events)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+// Package retrytest provides helpers for testing of code which uses retry
+//
+// assert on retry behavior example:
+//
+//	rec := retrytest.NewRecorder()
+//	err := retry.Do(fn, append(opts, rec.Options()...)...)
+//
+//	assert.Equal(t, uint(3), rec.Attempts())
+//	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, rec.Delays())
+package retrytest
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go"
+)
+
+// Clock is a fake retry.Clock which moves only by Advance
+// (or by Timer with the Clock set)
+type Clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewClock returns fake clock starting at start
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now returns current time of the clock
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Timer is a fake retry.Timer which records all delays and elapses
+// immediately
+type Timer struct {
+	// Clock (if set) is advanced by each delay, so the elapsed time
+	// measured by Do is the sum of the delays
+	Clock *Clock
+
+	mu     sync.Mutex
+	delays []time.Duration
+}
+
+// After records delay and returns channel which already received
+func (t *Timer) After(delay time.Duration) <-chan time.Time {
+	t.mu.Lock()
+	t.delays = append(t.delays, delay)
+	t.mu.Unlock()
+
+	var now time.Time
+	if t.Clock != nil {
+		t.Clock.Advance(delay)
+		now = t.Clock.Now()
+	}
+
+	ch := make(chan time.Time, 1)
+	ch <- now
+	return ch
+}
+
+// Delays returns all recorded delays in order
+func (t *Timer) Delays() []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]time.Duration(nil), t.delays...)
+}
+
+// Recorder records attempts, errors and delays of Do, see Options
+type Recorder struct {
+	// Seed of the random source set by Options (for jitter and
+	// RandomAttempts), so the recorded delays are deterministic
+	Seed int64
+
+	clock *Clock
+	timer *Timer
+
+	mu       sync.Mutex
+	attempts uint
+	errs     []error
+}
+
+// NewRecorder returns recorder with fake timer and clock starting at zero time
+func NewRecorder() *Recorder {
+	clock := NewClock(time.Time{})
+	return &Recorder{
+		Seed:  1,
+		clock: clock,
+		timer: &Timer{Clock: clock},
+	}
+}
+
+// Options returns options which connect the recorder to Do:
+// WithObserver, WithTimer, WithClock and WithRand
+//
+// The recorder observes attempts without replacing BeforeAttempt and
+// AfterAttempt of the caller, append the options after the own options
+// of Do only so the fake timer, clock and random source take effect.
+func (r *Recorder) Options() []retry.Option {
+	return []retry.Option{
+		retry.WithObserver(r),
+		retry.WithTimer(r.timer),
+		retry.WithClock(r.clock),
+		retry.WithRand(rand.New(rand.NewSource(r.Seed))),
+	}
+}
+
+// BeforeAttempt records started attempt, see retry.Observer
+func (r *Recorder) BeforeAttempt(n uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attempts++
+}
+
+// AfterAttempt records error of failed attempt, see retry.Observer
+func (r *Recorder) AfterAttempt(n uint, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+// Attempts returns count of started attempts
+func (r *Recorder) Attempts() uint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.attempts
+}
+
+// Errors returns errors of all failed attempts in order
+func (r *Recorder) Errors() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]error(nil), r.errs...)
+}
+
+// Delays returns all waits of Do in order (delays between attempts,
+// WithStartupJitter, WithSettleTime)
+func (r *Recorder) Delays() []time.Duration {
+	return r.timer.Delays()
+}
+
+// Elapsed returns sum of all delays, as measured by the fake clock
+func (r *Recorder) Elapsed() time.Duration {
+	return r.clock.Now().Sub(time.Time{})
+}
+
+// Clock returns the fake clock of the recorder
+func (r *Recorder) Clock() *Clock {
+	return r.clock
+}
+
+// Timer returns the fake timer of the recorder
+func (r *Recorder) Timer() *Timer {
+	return r.timer
+}
@@ -0,0 +1,99 @@
+package retrytest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/avast/retry-go/retrytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	rec := retrytest.NewRecorder()
+	testErr := errors.New("test")
+	calls := 0
+	err := retry.Do(
+		func() error {
+			calls++
+			if calls < 3 {
+				return testErr
+			}
+			return nil
+		},
+		append([]retry.Option{
+			retry.Attempts(5),
+			retry.Delay(100 * time.Millisecond),
+			retry.Units(time.Nanosecond),
+			retry.DelayType(retry.BackOffDelay),
+		}, rec.Options()...)...,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), rec.Attempts())
+	assert.Equal(t, []error{testErr, testErr}, rec.Errors())
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, rec.Delays())
+	assert.Equal(t, 300*time.Millisecond, rec.Elapsed())
+}
+
+func TestRecorderKeepsCallbacks(t *testing.T) {
+	rec := retrytest.NewRecorder()
+	var before, after uint
+	err := retry.Do(
+		func() error { return errors.New("test") },
+		append([]retry.Option{
+			retry.Attempts(2),
+			retry.BeforeAttempt(func(n uint) { before++ }),
+			retry.AfterAttempt(func(n uint, err error) { after++ }),
+		}, rec.Options()...)...,
+	)
+	assert.Error(t, err)
+	assert.Equal(t, uint(2), rec.Attempts())
+	assert.Equal(t, uint(2), before, "own BeforeAttempt isn't replaced")
+	assert.Equal(t, uint(2), after, "own AfterAttempt isn't replaced")
+}
+
+func TestRecorderElapsedInError(t *testing.T) {
+	rec := retrytest.NewRecorder()
+	err := retry.Do(
+		func() error { return errors.New("test") },
+		append([]retry.Option{
+			retry.Attempts(3),
+			retry.Delay(time.Second),
+			retry.Units(time.Nanosecond),
+			retry.DelayType(retry.FixedDelay),
+			retry.WithElapsedInError(true),
+		}, rec.Options()...)...,
+	)
+	assert.EqualError(t, err, "retry: failed after 3 attempts over 2s: test")
+	assert.Len(t, rec.Errors(), 3)
+}
+
+func TestRecorderDeterministicJitter(t *testing.T) {
+	run := func() []time.Duration {
+		rec := retrytest.NewRecorder()
+		retry.Do(
+			func() error { return errors.New("test") },
+			append([]retry.Option{
+				retry.Attempts(4),
+				retry.DelayType(retry.ConstantJitterDelay(time.Second, time.Second)),
+			}, rec.Options()...)...,
+		)
+		return rec.Delays()
+	}
+	delays := run()
+	assert.Len(t, delays, 3)
+	assert.Equal(t, delays, run(), "same seed, same delays")
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := retrytest.NewClock(start)
+	clock.Advance(time.Minute)
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+	timer := retrytest.Timer{Clock: clock}
+	<-timer.After(time.Second)
+	assert.Equal(t, start.Add(time.Minute+time.Second), clock.Now())
+	assert.Equal(t, []time.Duration{time.Second}, timer.Delays())
+}
@@ -0,0 +1,103 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+)
+
+// errFlightPanicked is the error of waiters for a call which panicked
+var errFlightPanicked = errors.New("retry: coalesced attempt panicked")
+
+// Group coalesces concurrent attempts with the same key,
+// see WithSingleFlight. The zero value is ready to use.
+type Group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+	// count of waiting calls, guarded by mu of Group
+	waiters int
+}
+
+// Do calls fn unless a call with the same key is in flight,
+// in that case it waits for that call and returns its error
+func (g *Group) Do(key string, fn func() error) error {
+	_, err := g.do(key, func() (interface{}, error) {
+		return nil, fn()
+	})
+	return err
+}
+
+// do is like Do, the waiting calls share value of the call as well
+func (g *Group) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		g.mu.Unlock()
+		c.wg.Wait()
+
+		g.mu.Lock()
+		c.waiters--
+		g.mu.Unlock()
+		return c.value, c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	returned := false
+	defer func() {
+		// the panic goes on in this call, the waiters fail
+		if !returned {
+			c.value, c.err = nil, errFlightPanicked
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+
+	c.value, c.err = fn()
+	returned = true
+	return c.value, c.err
+}
+
+// waiters returns count of calls waiting for the call with key
+func (g *Group) waiters(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if c, ok := g.calls[key]; ok {
+		return c.waiters
+	}
+	return 0
+}
+
+// WithSingleFlight coalesces attempts of concurrent Do calls with the same key
+// and group: only one of them calls retryable function (e.g. expensive
+// reconnect), the others wait for it and take its result as their attempt.
+//
+// It prevents a stampede of simultaneous recoveries of a shared resource.
+//
+//	var reconnect retry.Group
+//
+//	retry.Do(
+//		func() error {
+//			return pool.Rebuild()
+//		},
+//		retry.WithSingleFlight("pool", &reconnect),
+//	)
+func WithSingleFlight(key string, group *Group) Option {
+	return func(c *config) {
+		c.singleFlightKey = key
+		c.singleFlight = group
+	}
+}
@@ -0,0 +1,132 @@
+package retry
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// waitFor waits until cond is true
+func waitFor(t *testing.T, cond func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// testSingleFlight calls do concurrently by 5 goroutines with WithSingleFlight
+// option of the same key and checks they all share want of a single attempt,
+// do wraps the attempt (blocking until all the others wait for it) by Do* function
+func testSingleFlight(t *testing.T, want int, do func(opt Option, attempt func()) int) {
+	var group Group
+	var calls int32
+	key := t.Name()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	attempt := func() {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(started)
+		}
+		<-release
+	}
+
+	var wg sync.WaitGroup
+	values := make([]int, 5)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i] = do(WithSingleFlight(key, &group), attempt)
+		}(i)
+		if i == 0 {
+			<-started
+		}
+	}
+	waitFor(t, func() bool { return group.waiters(key) == 4 })
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "coalesced attempt")
+	assert.Equal(t, []int{want, want, want, want, want}, values, "coalesced calls share the result")
+}
+
+func TestWithSingleFlight(t *testing.T) {
+	var group Group
+	var calls int32
+	releases := []chan struct{}{make(chan struct{}), make(chan struct{})}
+
+	rebuild := func() error {
+		n := atomic.AddInt32(&calls, 1)
+		<-releases[n-1]
+		if n == 1 {
+			return errors.New("test")
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = Do(rebuild, WithSingleFlight("pool", &group), Units(time.Nanosecond))
+		}(i)
+	}
+
+	// all goroutines join the first attempt, it fails for all of them
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 1 && group.waiters("pool") == 4 })
+	close(releases[0])
+
+	// and all of them join the retry
+	waitFor(t, func() bool { return atomic.LoadInt32(&calls) == 2 && group.waiters("pool") == 4 })
+	close(releases[1])
+	wg.Wait()
+
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "the attempts are coalesced")
+}
+
+func TestGroupPanic(t *testing.T) {
+	var group Group
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	panicked := make(chan interface{})
+	go func() {
+		defer func() { panicked <- recover() }()
+		group.Do("a", func() error {
+			close(started)
+			<-release
+			panic("test")
+		})
+	}()
+	<-started
+
+	waited := make(chan error)
+	go func() {
+		waited <- group.Do("a", func() error { return nil })
+	}()
+	waitFor(t, func() bool { return group.waiters("a") == 1 })
+	close(release)
+
+	assert.Equal(t, "test", <-panicked, "the panic goes on in the call")
+	assert.Equal(t, errFlightPanicked, <-waited, "waiter fails instead of success")
+}
+
+func TestGroupDistinctKeys(t *testing.T) {
+	var group Group
+	var calls int
+	assert.NoError(t, group.Do("a", func() error { calls++; return nil }))
+	assert.NoError(t, group.Do("a", func() error { calls++; return nil }))
+	assert.Equal(t, 2, calls, "sequential calls aren't coalesced")
+}
@@ -0,0 +1,95 @@
+package retry
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// maximal depth of captured stack
+const stackDepth = 32
+
+// stackError wraps the final error of Do with stack of Do caller
+type stackError struct {
+	err   error
+	stack []uintptr
+}
+
+// WithStackTrace captures stack of Do caller and attaches it to the error
+// returned on final failure
+// default is false, capturing of stack has its cost
+//
+// The returned error has method StackTrace() []runtime.Frame and prints
+// the stack by %+v verb, it unwraps to the error which would be returned otherwise.
+//
+//	err := retry.Do(
+//		func() error {
+//			...
+//		},
+//		retry.WithStackTrace(true),
+//	)
+//	log.Printf("%+v", err)
+func WithStackTrace(enabled bool) Option {
+	return func(c *config) {
+		c.stackTrace = enabled
+	}
+}
+
+// callers captures stack above the caller of callers, skipping skip frames more
+func callers(skip int) []uintptr {
+	pcs := make([]uintptr, stackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	return pcs[:n]
+}
+
+// skipCallers makes the stack of WithStackTrace skip n more frames,
+// it's appended by entry points which call Do, so the stack starts at their caller
+func skipCallers(n int) Option {
+	return func(c *config) {
+		c.callerSkip += n
+	}
+}
+
+// callerStack sets the stack of WithStackTrace captured by an entry point
+// which calls Do from other goroutine (e.g. DoAll)
+func callerStack(stack []uintptr) Option {
+	return func(c *config) {
+		c.stack = stack
+	}
+}
+
+func (e *stackError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stackError) Unwrap() error {
+	return e.err
+}
+
+// StackTrace returns frames of stack where Do was called
+func (e *stackError) StackTrace() []runtime.Frame {
+	var stack []runtime.Frame
+	frames := runtime.CallersFrames(e.stack)
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, frame)
+		if !more {
+			return stack
+		}
+	}
+}
+
+// Format prints the stack with the error by %+v verb
+func (e *stackError) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "%+v", e.err)
+		for _, frame := range e.StackTrace() {
+			fmt.Fprintf(s, "\n%s\n\t%s:%d", frame.Function, frame.File, frame.Line)
+		}
+	case verb == 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	default:
+		io.WriteString(s, e.Error())
+	}
+}
@@ -0,0 +1,71 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStackTrace(t *testing.T) {
+	originalErr := errors.New("test")
+	err := Do(
+		func() error { return originalErr },
+		Attempts(2),
+		Units(time.Nanosecond),
+		WithStackTrace(true),
+	)
+	assert.Error(t, err)
+	assert.Equal(t, "test", err.Error(), "message is unchanged")
+	var retryErr Error
+	assert.True(t, errors.As(err, &retryErr), "unwraps to Error")
+	assert.Len(t, retryErr, 2)
+
+	var traced interface{ StackTrace() []runtime.Frame }
+	assert.True(t, errors.As(err, &traced))
+	stack := traced.StackTrace()
+	assert.NotEmpty(t, stack)
+	assert.True(t, strings.HasSuffix(stack[0].Function, "TestWithStackTrace"), "stack starts at Do caller")
+
+	assert.Contains(t, fmt.Sprintf("%+v", err), "stack_test.go")
+	assert.Equal(t, "test", fmt.Sprintf("%v", err))
+
+	err = Do(
+		func() error { return originalErr },
+		Attempts(2),
+		Units(time.Nanosecond),
+	)
+	assert.False(t, errors.As(err, &traced), "stack trace is opt-in")
+
+	assert.NoError(t, Do(func() error { return nil }, WithStackTrace(true)))
+}
+
+func TestWithStackTraceEntryPoints(t *testing.T) {
+	testErr := errors.New("test")
+	opts := []Option{Attempts(1), WithStackTrace(true)}
+	first := func(err error) string {
+		var traced interface{ StackTrace() []runtime.Frame }
+		if !errors.As(err, &traced) || len(traced.StackTrace()) == 0 {
+			return ""
+		}
+		return traced.StackTrace()[0].Function
+	}
+	caller := "TestWithStackTraceEntryPoints"
+
+	err := New(opts...).Do(func() error { return testErr })
+	assert.True(t, strings.HasSuffix(first(err), caller), "Retrier.Do: %s", first(err))
+
+	_, err = PollWithData(context.Background(),
+		func(context.Context) (int, bool, error) { return 0, false, testErr },
+		opts...,
+	)
+	assert.True(t, strings.HasSuffix(first(err), caller), "PollWithData: %s", first(err))
+
+	errs := DoAll([]RetryableFunc{func() error { return testErr }}, opts...)
+	assert.True(t, strings.HasSuffix(first(errs[0]), caller), "DoAll: %s", first(errs[0]))
+}